@@ -0,0 +1,218 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotmc/usbtmc/driver"
+)
+
+// abortTimeout bounds the abort sequence, which runs after the caller's
+// context is already done and therefore cannot use it.
+const abortTimeout = 5 * time.Second
+
+// AbortError is returned when a transfer is abandoned because its context was
+// cancelled, its deadline passed, or the transfer itself timed out. Before
+// returning an AbortError, the Device aborts the transfer on the instrument so
+// that the next exchange starts cleanly.
+type AbortError struct {
+	// Op is the operation that was aborted, either "write" or "read".
+	Op string
+	// N is the number of message data bytes that were transferred before the
+	// abort. For writes this is the number of bytes the device reports it
+	// actually accepted.
+	N int
+	// Err is the context or transfer error that caused the abort, joined with
+	// any error from the abort sequence itself.
+	Err error
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("usbtmc: %s aborted after %d bytes: %v", e.Op, e.N, e.Err)
+}
+
+func (e *AbortError) Unwrap() error {
+	return e.Err
+}
+
+// timedOut reports whether a transfer that failed with err must be aborted
+// because ctx is done or because the transfer timed out. The driver can time
+// out a transfer slightly before ctx's deadline passes, so ctx.Err alone is
+// not enough.
+func timedOut(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, driver.ErrTimeout)
+}
+
+// abortContext returns a context for running an abort sequence once ctx is
+// done. It keeps the values of ctx but not its cancellation.
+func abortContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+}
+
+// abortBulkOut aborts the Bulk-OUT transfer with the given bTag using the
+// INITIATE_ABORT_BULK_OUT and CHECK_ABORT_BULK_OUT_STATUS requests and returns
+// the number of bytes the device received in that transfer. The caller must
+// hold d.mu.
+func (d *Device) abortBulkOut(ctx context.Context, bTag byte) (int, error) {
+	ep := d.usbDevice.BulkOutEndpointAddress()
+
+	// Per USBTMC Table 19, wValue holds the bTag of the transfer to abort and
+	// the response is the USBTMC_status followed by the bTag.
+	if _, err := d.controlEndpointIn(
+		ctx, initiateAbortBulkOut, uint16(bTag), ep, 2,
+	); err != nil {
+		return 0, err
+	}
+
+	// Per USBTMC Table 23, the CHECK_ABORT_BULK_OUT_STATUS response is the
+	// USBTMC_status, three reserved bytes, and NBYTES_RXD.
+	for {
+		resp, err := d.controlEndpointIn(ctx, checkAbortBulkOutStatus, 0, ep, 8)
+		if errors.Is(err, ErrStatusPending) {
+			if err := pollWait(ctx); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		nbytes := int(binary.LittleEndian.Uint32(resp[4:8]))
+		return nbytes, d.clearHalt(ctx, ep)
+	}
+}
+
+// abortWrite aborts the message being written after ctx is done or a transfer
+// timed out. The transfer tagged bTag carried length message bytes starting
+// at message offset pos and ended the message if eom is set. The inFlight flag
+// reports whether that transfer was interrupted rather than having completed.
+// It returns the number of message bytes the device accepted along with an
+// *AbortError. The caller must hold d.mu.
+func (d *Device) abortWrite(
+	ctx context.Context,
+	cause error,
+	bTag byte,
+	pos, length int,
+	inFlight, eom bool,
+) (int, error) {
+	actx, cancel := abortContext(ctx)
+	defer cancel()
+
+	accepted := pos + length
+	completed := !inFlight
+	var err error
+	if inFlight {
+		var nbytes int
+		nbytes, err = d.abortBulkOut(actx, bTag)
+		switch {
+		case err == nil:
+			accepted = pos + nbytes
+		case errors.Is(err, ErrTransferNotInProgress),
+			errors.Is(err, ErrStatusFailed):
+			// The transfer finished before the device saw the abort.
+			completed, err = true, nil
+		}
+	}
+	// Transfers that completed before the abort leave a partial message on
+	// the device that the Bulk-OUT abort cannot discard, so clear the
+	// interface unless the whole message made it across.
+	if err == nil && !(completed && eom) && (pos > 0 || completed) {
+		err = d.clear(actx)
+	}
+	if err != nil {
+		cause = errors.Join(cause, err)
+	}
+	return accepted, &AbortError{Op: "write", N: accepted, Err: cause}
+}
+
+// abortBulkIn aborts the Bulk-IN transfer with the given bTag using the
+// INITIATE_ABORT_BULK_IN and CHECK_ABORT_BULK_IN_STATUS requests, reading and
+// discarding whatever the device had queued. The caller must hold d.mu.
+func (d *Device) abortBulkIn(ctx context.Context, bTag byte) error {
+	ep := d.usbDevice.BulkInEndpointAddress()
+
+	// Per USBTMC Table 25, wValue holds the bTag of the transfer to abort and
+	// the response is the USBTMC_status followed by the bTag.
+	if _, err := d.controlEndpointIn(
+		ctx, initiateAbortBulkIn, uint16(bTag), ep, 2,
+	); err != nil {
+		return err
+	}
+
+	// Per USBTMC section 4.2.1.4, the host must read from the Bulk-IN
+	// endpoint until it receives a short packet before checking the abort
+	// status. The CHECK_ABORT_BULK_IN_STATUS response (Table 29) is the
+	// USBTMC_status, bmAbortBulkIn, two reserved bytes, and NBYTES_TXD. While
+	// the status is STATUS_PENDING, D0 of bmAbortBulkIn reports whether the
+	// Bulk-IN FIFO still holds data that must be read.
+	if err := d.drainBulkIn(ctx); err != nil {
+		return err
+	}
+	for {
+		resp, err := d.controlEndpointIn(ctx, checkAbortBulkInStatus, 0, ep, 8)
+		if !errors.Is(err, ErrStatusPending) {
+			return err
+		}
+		if len(resp) > 1 && resp[1]&0x01 != 0 {
+			err = d.drainBulkIn(ctx)
+		} else {
+			err = pollWait(ctx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// abortRead aborts the Bulk-IN transfer tagged bTag after ctx is done or a
+// transfer timed out, having already received pos message bytes, and returns
+// pos along with an *AbortError. If the device no longer has the transfer in
+// progress, the interface is cleared so that no stale response data is left
+// behind for the next read. The caller must hold d.mu.
+func (d *Device) abortRead(
+	ctx context.Context,
+	cause error,
+	bTag byte,
+	pos int,
+) (int, error) {
+	actx, cancel := abortContext(ctx)
+	defer cancel()
+
+	err := d.abortBulkIn(actx, bTag)
+	if errors.Is(err, ErrTransferNotInProgress) || errors.Is(err, ErrStatusFailed) {
+		err = d.clear(actx)
+	}
+	if err != nil {
+		cause = errors.Join(cause, err)
+	}
+	return pos, &AbortError{Op: "read", N: pos, Err: cause}
+}
+
+// abortRequest aborts the Bulk-OUT transfer tagged bTag that carried a
+// REQUEST_DEV_DEP_MSG_IN or REQUEST_VENDOR_SPECIFIC_IN header, after ctx is
+// done or the transfer timed out, and returns an *AbortError for the read. No
+// Bulk-IN transfer has started, so only the Bulk-OUT transfer is aborted. If
+// the request reached the device before the abort, the interface is cleared
+// so that its response is not left behind for the next read. The caller must
+// hold d.mu.
+func (d *Device) abortRequest(ctx context.Context, cause error, bTag byte) error {
+	actx, cancel := abortContext(ctx)
+	defer cancel()
+
+	_, err := d.abortBulkOut(actx, bTag)
+	if errors.Is(err, ErrTransferNotInProgress) || errors.Is(err, ErrStatusFailed) {
+		err = d.clear(actx)
+	}
+	if err != nil {
+		cause = errors.Join(cause, err)
+	}
+	return &AbortError{Op: "read", Err: cause}
+}
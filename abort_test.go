@@ -0,0 +1,357 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gotmc/usbtmc/driver"
+)
+
+func TestWriteBinaryAbortInFlight(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel the context while the second transfer is on the bus.
+	mock.onWrite = func(i int) error {
+		if i == 1 {
+			cancel()
+			return errors.New("mock: transfer cancelled")
+		}
+		return nil
+	}
+	mock.reply(initiateAbortBulkOut, byte(statusSuccess), 2)
+	mock.reply(checkAbortBulkOutStatus, byte(statusPending), 0, 0, 0, 0, 0, 0, 0)
+	mock.reply(checkAbortBulkOutStatus, byte(statusSuccess), 0, 0, 0, 64, 0, 0, 0)
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusSuccess), 0)
+
+	dev.SetMaxTransferSize(512)
+	n, err := dev.WriteBinary(ctx, make([]byte, 1200))
+	var ae *AbortError
+	if !errors.As(err, &ae) {
+		t.Fatalf("error = %v, want *AbortError", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	// The first 500-byte transfer completed and the device reports receiving
+	// 64 bytes of the second.
+	if n != 564 || ae.N != 564 {
+		t.Errorf("accepted = %d (AbortError.N %d), want 564", n, ae.N)
+	}
+	if c := mock.controls[0]; c.bmRequestType != 0xa2 || c.wValue != 2 ||
+		c.wIndex != 0x02 {
+		t.Errorf("INITIATE_ABORT_BULK_OUT setup = %+v, want 0xa2, bTag 2, ep 0x02", c)
+	}
+	// The first transfer left a partial message on the device, so the abort
+	// is followed by a clear.
+	want := []uint8{
+		uint8(initiateAbortBulkOut),
+		uint8(checkAbortBulkOutStatus),
+		uint8(checkAbortBulkOutStatus),
+		requestClearFeature,
+		uint8(initiateClear),
+		uint8(checkClearStatus),
+		requestClearFeature,
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
+
+func TestWriteBinaryAbortSingleTransfer(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mock.onWrite = func(int) error {
+		cancel()
+		return errors.New("mock: transfer cancelled")
+	}
+	mock.reply(initiateAbortBulkOut, byte(statusSuccess), 1)
+	mock.reply(checkAbortBulkOutStatus, byte(statusSuccess), 0, 0, 0, 4, 0, 0, 0)
+
+	n, err := dev.WriteBinary(ctx, []byte("*RST\n"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if n != 4 {
+		t.Errorf("accepted = %d, want 4", n)
+	}
+	// Aborting the only transfer discards the whole message, so no clear.
+	want := []uint8{
+		uint8(initiateAbortBulkOut),
+		uint8(checkAbortBulkOutStatus),
+		requestClearFeature,
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
+
+func TestWriteBinaryAbortTransferTimeout(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	// The driver times out the transfer while ctx is still live.
+	mock.onWrite = func(int) error {
+		return fmt.Errorf("mock: write: %w", driver.ErrTimeout)
+	}
+	mock.reply(initiateAbortBulkOut, byte(statusSuccess), 1)
+	mock.reply(checkAbortBulkOutStatus, byte(statusSuccess), 0, 0, 0, 0, 0, 0, 0)
+
+	_, err := dev.WriteBinary(ctx, []byte("*RST\n"))
+	var ae *AbortError
+	if !errors.As(err, &ae) {
+		t.Fatalf("error = %v, want *AbortError", err)
+	}
+	if !errors.Is(err, driver.ErrTimeout) {
+		t.Errorf("error = %v, want driver.ErrTimeout", err)
+	}
+	want := []uint8{
+		uint8(initiateAbortBulkOut),
+		uint8(checkAbortBulkOutStatus),
+		requestClearFeature,
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
+
+func TestWriteBinaryCancelBetweenTransfers(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mock.onWrite = func(int) error {
+		cancel()
+		return nil
+	}
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusSuccess), 0)
+
+	dev.SetMaxTransferSize(512)
+	n, err := dev.WriteBinary(ctx, make([]byte, 1200))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if n != 500 {
+		t.Errorf("accepted = %d, want 500", n)
+	}
+	want := []uint8{
+		uint8(initiateClear),
+		uint8(checkClearStatus),
+		requestClearFeature,
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
+
+func TestReadBinaryAbort(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The device announces a 1000 byte transfer but the context is cancelled
+	// after the first packet arrives.
+	first := buildDevDepMsgInResponse(1, make([]byte, 1000))[:defaultMaxPacketSize]
+	mock.reads = [][]byte{
+		first,
+		make([]byte, defaultMaxPacketSize), // drained after INITIATE_ABORT_BULK_IN
+		make([]byte, 20),                   // short packet ends the drain
+		make([]byte, 8),                    // drained after CHECK_ABORT_BULK_IN_STATUS
+		buildDevDepMsgInResponse(2, []byte("next\n")),
+	}
+	mock.onRead = func(i int) error {
+		if i == 1 && ctx.Err() == nil {
+			cancel()
+			return errors.New("mock: transfer cancelled")
+		}
+		return nil
+	}
+	mock.reply(initiateAbortBulkIn, byte(statusSuccess), 1)
+	mock.reply(checkAbortBulkInStatus, byte(statusPending), 0x01, 0, 0, 0, 0, 0, 0)
+	mock.reply(checkAbortBulkInStatus, byte(statusSuccess), 0, 0, 0, 0, 0, 0, 0)
+
+	buf := make([]byte, 1000)
+	n, err := dev.ReadBinary(ctx, buf)
+	var ae *AbortError
+	if !errors.As(err, &ae) || ae.Op != "read" {
+		t.Fatalf("error = %v, want read *AbortError", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if want := defaultMaxPacketSize - usbtmcHeaderLen; n != want {
+		t.Errorf("n = %d, want %d", n, want)
+	}
+	if c := mock.controls[0]; c.bmRequestType != 0xa2 || c.wValue != 1 ||
+		c.wIndex != 0x81 {
+		t.Errorf("INITIATE_ABORT_BULK_IN setup = %+v, want 0xa2, bTag 1, ep 0x81", c)
+	}
+	want := []uint8{
+		uint8(initiateAbortBulkIn),
+		uint8(checkAbortBulkInStatus),
+		uint8(checkAbortBulkInStatus),
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+
+	// The device must be ready for the next exchange.
+	n, err = dev.ReadBinary(context.Background(), buf)
+	if err != nil {
+		t.Fatalf("ReadBinary after abort returned error: %v", err)
+	}
+	if got := string(buf[:n]); got != "next\n" {
+		t.Errorf("ReadBinary after abort = %q, want %q", got, "next\n")
+	}
+}
+
+func TestReadBinaryAbortNotInProgress(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	mock.onRead = func(int) error {
+		cancel()
+		return errors.New("mock: transfer timed out")
+	}
+	mock.reply(initiateAbortBulkIn, byte(statusTransferNotInProgress), 1)
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusSuccess), 0)
+
+	_, err := dev.ReadBinary(ctx, make([]byte, 100))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	want := []uint8{
+		uint8(initiateAbortBulkIn),
+		uint8(initiateClear),
+		uint8(checkClearStatus),
+		requestClearFeature,
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
+
+func TestReadBinaryAbortTransferTimeout(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	// The driver times out the transfer while ctx is still live.
+	mock.reads = [][]byte{
+		make([]byte, 0), // short packet ends the drain
+		buildDevDepMsgInResponse(2, []byte("next\n")),
+	}
+	mock.onRead = func(i int) error {
+		if i == 0 && len(mock.controls) == 0 {
+			return fmt.Errorf("mock: read: %w", driver.ErrTimeout)
+		}
+		return nil
+	}
+	mock.reply(initiateAbortBulkIn, byte(statusSuccess), 1)
+	mock.reply(checkAbortBulkInStatus, byte(statusSuccess), 0, 0, 0, 0, 0, 0, 0)
+
+	buf := make([]byte, 100)
+	_, err := dev.ReadBinary(ctx, buf)
+	var ae *AbortError
+	if !errors.As(err, &ae) || ae.Op != "read" {
+		t.Fatalf("error = %v, want read *AbortError", err)
+	}
+	if !errors.Is(err, driver.ErrTimeout) {
+		t.Errorf("error = %v, want driver.ErrTimeout", err)
+	}
+	want := []uint8{
+		uint8(initiateAbortBulkIn),
+		uint8(checkAbortBulkInStatus),
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+
+	// The device must be ready for the next exchange.
+	n, err := dev.ReadBinary(context.Background(), buf)
+	if err != nil {
+		t.Fatalf("ReadBinary after abort returned error: %v", err)
+	}
+	if got := string(buf[:n]); got != "next\n" {
+		t.Errorf("ReadBinary after abort = %q, want %q", got, "next\n")
+	}
+}
+
+func TestReadBinaryAbortRequestTimeout(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status status
+		want   []uint8
+	}{
+		{
+			"aborted",
+			statusSuccess,
+			[]uint8{
+				uint8(initiateAbortBulkOut),
+				uint8(checkAbortBulkOutStatus),
+				requestClearFeature,
+			},
+		},
+		{
+			// The request reached the device, so its response is cleared.
+			"not_in_progress",
+			statusTransferNotInProgress,
+			[]uint8{
+				uint8(initiateAbortBulkOut),
+				uint8(initiateClear),
+				uint8(checkClearStatus),
+				requestClearFeature,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockUSBDevice{}
+			dev := newTestDevice(mock)
+
+			// The REQUEST_DEV_DEP_MSG_IN header write times out.
+			mock.onWrite = func(int) error {
+				return fmt.Errorf("mock: write: %w", driver.ErrTimeout)
+			}
+			mock.reply(initiateAbortBulkOut, byte(tc.status), 1)
+			mock.reply(checkAbortBulkOutStatus, byte(statusSuccess), 0, 0, 0, 0, 0, 0, 0)
+			mock.reply(initiateClear, byte(statusSuccess))
+			mock.reply(checkClearStatus, byte(statusSuccess), 0)
+
+			_, err := dev.ReadBinary(context.Background(), make([]byte, 100))
+			var ae *AbortError
+			if !errors.As(err, &ae) || ae.Op != "read" {
+				t.Fatalf("error = %v, want read *AbortError", err)
+			}
+			if c := mock.controls[0]; c.wValue != 1 || c.wIndex != 0x02 {
+				t.Errorf("INITIATE_ABORT_BULK_OUT setup = %+v, want bTag 1, ep 0x02", c)
+			}
+			if got := mock.requests(); !equalRequests(got, tc.want...) {
+				t.Errorf("control requests = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// capabilitiesLen is the wLength of the GET_CAPABILITIES response packet.
+// Per USBTMC Table 37 the response is 0x18 bytes long.
+const capabilitiesLen = 0x18
+
+// Capabilities describes the attributes and capabilities of a USBTMC
+// interface as reported in the GET_CAPABILITIES response packet. The USB488
+// fields are only meaningful for USB488 interfaces, which report a non-zero
+// USB488Version.
+type Capabilities struct {
+	// USBTMCVersion is the BCD version of the USBTMC specification supported
+	// by the interface (e.g., 0x0100 for version 1.00).
+	USBTMCVersion uint16
+
+	// IndicatorPulse reports whether the interface accepts the
+	// INDICATOR_PULSE request.
+	IndicatorPulse bool
+	// TalkOnly reports whether the interface is talk-only.
+	TalkOnly bool
+	// ListenOnly reports whether the interface is listen-only.
+	ListenOnly bool
+	// TermChar reports whether the device supports ending a Bulk-IN transfer
+	// when a byte matches the specified TermChar.
+	TermChar bool
+
+	// USB488Version is the BCD version of the USBTMC-USB488 specification
+	// supported by the interface. It is zero for plain USBTMC interfaces.
+	USB488Version uint16
+
+	// USB4882 reports whether the interface is a USB488.2 interface.
+	USB4882 bool
+	// RemoteLocal reports whether the interface accepts the REN_CONTROL,
+	// GO_TO_LOCAL, and LOCAL_LOCKOUT requests.
+	RemoteLocal bool
+	// Trigger reports whether the interface accepts the TRIGGER USBTMC
+	// command message.
+	Trigger bool
+	// SCPI reports whether the device understands all of the mandatory SCPI
+	// commands.
+	SCPI bool
+	// SR1 reports whether the device is SR1 capable and can therefore send
+	// service requests on the Interrupt-IN endpoint.
+	SR1 bool
+	// RL1 reports whether the device is RL1 capable (full remote/local).
+	RL1 bool
+	// DT1 reports whether the device is DT1 capable (device trigger).
+	DT1 bool
+}
+
+// decodeCapabilities decodes the GET_CAPABILITIES response packet per USBTMC
+// Table 37 and USB488 Table 8.
+func decodeCapabilities(resp []byte) (Capabilities, error) {
+	if len(resp) < capabilitiesLen {
+		return Capabilities{}, fmt.Errorf(
+			"usbtmc: capabilities response too short: got %d bytes, want %d",
+			len(resp), capabilitiesLen)
+	}
+	// Offset 4: USBTMC interface capabilities.
+	// Offset 5: USBTMC device capabilities.
+	// Offset 14: USB488 interface capabilities.
+	// Offset 15: USB488 device capabilities.
+	usbtmcIntf, usbtmcDev := resp[4], resp[5]
+	usb488Intf, usb488Dev := resp[14], resp[15]
+	return Capabilities{
+		USBTMCVersion:  binary.LittleEndian.Uint16(resp[2:4]),
+		IndicatorPulse: usbtmcIntf&0x04 != 0,
+		TalkOnly:       usbtmcIntf&0x02 != 0,
+		ListenOnly:     usbtmcIntf&0x01 != 0,
+		TermChar:       usbtmcDev&0x01 != 0,
+		USB488Version:  binary.LittleEndian.Uint16(resp[12:14]),
+		USB4882:        usb488Intf&0x04 != 0,
+		RemoteLocal:    usb488Intf&0x02 != 0,
+		Trigger:        usb488Intf&0x01 != 0,
+		SCPI:           usb488Dev&0x08 != 0,
+		SR1:            usb488Dev&0x04 != 0,
+		RL1:            usb488Dev&0x02 != 0,
+		DT1:            usb488Dev&0x01 != 0,
+	}, nil
+}
+
+// Capabilities returns the attributes and capabilities of the USBTMC
+// interface. The GET_CAPABILITIES request is sent to the device the first time
+// Capabilities is called and the decoded response is cached on the Device for
+// use by later operations.
+func (d *Device) Capabilities(ctx context.Context) (Capabilities, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.capabilities(ctx)
+}
+
+// capabilities returns the cached capabilities, sending the GET_CAPABILITIES
+// request if needed. The caller must hold d.mu.
+func (d *Device) capabilities(ctx context.Context) (Capabilities, error) {
+	if d.caps != nil {
+		return *d.caps, nil
+	}
+	resp, err := d.controlIn(ctx, getCapabilities, 0, capabilitiesLen)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	caps, err := decodeCapabilities(resp)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	d.caps = &caps
+	return caps, nil
+}
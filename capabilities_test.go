@@ -0,0 +1,146 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// capabilitiesResponse builds a GET_CAPABILITIES response packet with the
+// given USBTMC and USB488 capability bytes.
+func capabilitiesResponse(
+	usbtmcIntf, usbtmcDev, usb488Intf, usb488Dev byte,
+) []byte {
+	resp := make([]byte, capabilitiesLen)
+	resp[0] = byte(statusSuccess)
+	resp[2], resp[3] = 0x00, 0x01 // bcdUSBTMC 1.00
+	resp[4], resp[5] = usbtmcIntf, usbtmcDev
+	resp[12], resp[13] = 0x00, 0x01 // bcdUSB488 1.00
+	resp[14], resp[15] = usb488Intf, usb488Dev
+	return resp
+}
+
+func TestDecodeCapabilities(t *testing.T) {
+	testCases := []struct {
+		name string
+		resp []byte
+		want Capabilities
+	}{
+		{
+			"usb488_full",
+			capabilitiesResponse(0x04, 0x01, 0x07, 0x0f),
+			Capabilities{
+				USBTMCVersion:  0x0100,
+				IndicatorPulse: true,
+				TermChar:       true,
+				USB488Version:  0x0100,
+				USB4882:        true,
+				RemoteLocal:    true,
+				Trigger:        true,
+				SCPI:           true,
+				SR1:            true,
+				RL1:            true,
+				DT1:            true,
+			},
+		},
+		{
+			"talk_only_no_usb488",
+			capabilitiesResponse(0x02, 0x00, 0x00, 0x00),
+			Capabilities{
+				USBTMCVersion: 0x0100,
+				TalkOnly:      true,
+				USB488Version: 0x0100,
+			},
+		},
+		{
+			"listen_only_trigger",
+			capabilitiesResponse(0x01, 0x00, 0x01, 0x01),
+			Capabilities{
+				USBTMCVersion: 0x0100,
+				ListenOnly:    true,
+				USB488Version: 0x0100,
+				Trigger:       true,
+				DT1:           true,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodeCapabilities(tc.resp)
+			if err != nil {
+				t.Fatalf("decodeCapabilities returned error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("decodeCapabilities = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesCached(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(getCapabilities, capabilitiesResponse(0x04, 0x01, 0x07, 0x0f)...)
+	dev := newTestDevice(mock)
+
+	for i := 0; i < 2; i++ {
+		caps, err := dev.Capabilities(context.Background())
+		if err != nil {
+			t.Fatalf("Capabilities returned error: %v", err)
+		}
+		if !caps.TermChar || !caps.SR1 {
+			t.Errorf("Capabilities = %+v, want TermChar and SR1 set", caps)
+		}
+	}
+	if len(mock.controls) != 1 {
+		t.Fatalf("expected 1 control transfer, got %d", len(mock.controls))
+	}
+	c := mock.controls[0]
+	if c.bmRequestType != 0xa1 || c.bRequest != uint8(getCapabilities) {
+		t.Errorf("setup = %#x/%d, want 0xa1/%d",
+			c.bmRequestType, c.bRequest, getCapabilities)
+	}
+	if c.wLength != capabilitiesLen {
+		t.Errorf("wLength = %d, want %d", c.wLength, capabilitiesLen)
+	}
+}
+
+func TestCapabilitiesFailedStatus(t *testing.T) {
+	mock := &mockUSBDevice{}
+	resp := make([]byte, capabilitiesLen)
+	resp[0] = byte(statusFailed)
+	mock.reply(getCapabilities, resp...)
+	dev := newTestDevice(mock)
+
+	_, err := dev.Capabilities(context.Background())
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("error = %v, want *StatusError", err)
+	}
+	if !errors.Is(err, ErrStatusFailed) {
+		t.Errorf("error = %v, want ErrStatusFailed", err)
+	}
+	if dev.caps != nil {
+		t.Error("failed GET_CAPABILITIES response was cached")
+	}
+}
+
+func TestCapabilitiesUsesInterfaceNumber(t *testing.T) {
+	mock := &mockUSBDevice{iface: 2}
+	mock.reply(getCapabilities, capabilitiesResponse(0x04, 0x01, 0x07, 0x0f)...)
+	dev := newTestDevice(mock)
+
+	if _, err := dev.Capabilities(context.Background()); err != nil {
+		t.Fatalf("Capabilities returned error: %v", err)
+	}
+	if len(mock.controls) != 1 {
+		t.Fatalf("expected 1 control transfer, got %d", len(mock.controls))
+	}
+	if got := mock.controls[0].wIndex; got != 2 {
+		t.Errorf("wIndex = %d, want the interface number 2", got)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+)
+
+// Clear clears all previously sent pending and unprocessed Bulk-OUT USBTMC
+// message content and all pending Bulk-IN transfers from the USBTMC
+// interface. Clear is the recovery mechanism for an instrument that is stuck
+// mid-message, such as after an interrupted query.
+//
+// Clear sends INITIATE_CLEAR and then polls CHECK_CLEAR_STATUS while the
+// device reports STATUS_PENDING, reading and discarding Bulk-IN data whenever
+// the device indicates its Bulk-IN FIFO is not empty. Once the clear
+// completes, the Bulk-OUT endpoint halt is cleared and the bTag sequence is
+// reset to its starting value.
+func (d *Device) Clear(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.clear(ctx)
+}
+
+// clear implements Clear. The caller must hold d.mu.
+func (d *Device) clear(ctx context.Context) error {
+	// Per USBTMC Table 31, the INITIATE_CLEAR response is a single
+	// USBTMC_status byte.
+	if _, err := d.controlIn(ctx, initiateClear, 0, 1); err != nil {
+		return err
+	}
+
+	// Per USBTMC Table 33, the CHECK_CLEAR_STATUS response is the
+	// USBTMC_status followed by bmClear. D0 of bmClear is set when the
+	// Bulk-IN FIFO is not empty, in which case the host must read from the
+	// Bulk-IN endpoint until a short packet is received before checking the
+	// status again.
+	for {
+		resp, err := d.controlIn(ctx, checkClearStatus, 0, 2)
+		if !errors.Is(err, ErrStatusPending) {
+			if err != nil {
+				return err
+			}
+			break
+		}
+		if len(resp) > 1 && resp[1]&0x01 != 0 {
+			err = d.drainBulkIn(ctx)
+		} else {
+			err = pollWait(ctx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := d.clearHalt(ctx, d.usbDevice.BulkOutEndpointAddress()); err != nil {
+		return err
+	}
+	d.bTag = d.startTag
+	d.discardPending()
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClear(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(initiateClear, byte(statusSuccess))
+	// First check: pending with the Bulk-IN FIFO not empty, so the host must
+	// drain Bulk-IN. Second check: pending with nothing to drain. Third
+	// check: success.
+	mock.reply(checkClearStatus, byte(statusPending), 0x01)
+	mock.reply(checkClearStatus, byte(statusPending), 0x00)
+	mock.reply(checkClearStatus, byte(statusSuccess), 0x00)
+	mock.reads = [][]byte{make([]byte, defaultMaxPacketSize), make([]byte, 10)}
+	dev := newTestDevice(mock)
+	dev.startTag = 7
+	dev.bTag = 42
+
+	if err := dev.Clear(context.Background()); err != nil {
+		t.Fatalf("Clear returned error: %v", err)
+	}
+	if mock.readN != 2 {
+		t.Errorf("drained %d Bulk-IN packets, want 2", mock.readN)
+	}
+	wantRequests := []uint8{
+		uint8(initiateClear),
+		uint8(checkClearStatus),
+		uint8(checkClearStatus),
+		uint8(checkClearStatus),
+		requestClearFeature,
+	}
+	if len(mock.controls) != len(wantRequests) {
+		t.Fatalf("got %d control transfers, want %d",
+			len(mock.controls), len(wantRequests))
+	}
+	for i, want := range wantRequests {
+		if got := mock.controls[i].bRequest; got != want {
+			t.Errorf("control[%d].bRequest = %d, want %d", i, got, want)
+		}
+	}
+	halt := mock.controls[len(mock.controls)-1]
+	if halt.wIndex != 0x02 {
+		t.Errorf("cleared halt on endpoint %#x, want 0x02", halt.wIndex)
+	}
+	if dev.bTag != 7 {
+		t.Errorf("bTag = %d after Clear, want 7", dev.bTag)
+	}
+}
+
+func TestClearFailed(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusFailed), 0x00)
+	dev := newTestDevice(mock)
+
+	err := dev.Clear(context.Background())
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("error = %v, want *StatusError", err)
+	}
+	if se.Request != uint8(checkClearStatus) || !errors.Is(err, ErrStatusFailed) {
+		t.Errorf("error = %v, want CHECK_CLEAR_STATUS STATUS_FAILED", err)
+	}
+}
+
+func TestClearDrainFullSpeed(t *testing.T) {
+	mock := &mockUSBDevice{packet: 64}
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusPending), 0x01)
+	mock.reply(checkClearStatus, byte(statusSuccess), 0x00)
+	// Two full 64-byte packets followed by a short one.
+	mock.reads = [][]byte{make([]byte, 64), make([]byte, 64), make([]byte, 3)}
+	dev := newTestDevice(mock)
+
+	if err := dev.Clear(context.Background()); err != nil {
+		t.Fatalf("Clear returned error: %v", err)
+	}
+	if mock.readN != 3 {
+		t.Errorf("drained %d Bulk-IN packets, want 3", mock.readN)
+	}
+	for i, n := range mock.readLens {
+		if n != 64 {
+			t.Errorf("drain read %d used a %d-byte buffer, want 64", i, n)
+		}
+	}
+}
+
+func TestClearStalledBulkIn(t *testing.T) {
+	mock := &mockUSBDevice{halted: map[uint8]bool{0x81: true}}
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusPending), 0x01)
+	mock.reply(checkClearStatus, byte(statusSuccess), 0x00)
+	dev := newTestDevice(mock)
+
+	if err := dev.Clear(context.Background()); err != nil {
+		t.Fatalf("Clear returned error: %v", err)
+	}
+	want := []uint8{
+		uint8(initiateClear),
+		uint8(checkClearStatus),
+		requestClearFeature,
+		uint8(checkClearStatus),
+		requestClearFeature,
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+	if c := mock.controls[2]; c.wIndex != 0x81 {
+		t.Errorf("cleared halt on endpoint %#x, want 0x81", c.wIndex)
+	}
+}
@@ -5,6 +5,8 @@
 
 package usbtmc
 
+import "fmt"
+
 type bInterfaceClass byte
 
 const reservedField = 0x00
@@ -117,3 +119,30 @@ const (
 	statusSplitNotInProgress    status = 0x82 // STATUS_SPLIT_NOT_IN_PROGRESS
 	statusSplitInProgress       status = 0x83 // STATUS_SPLIT_IN_PROGRESS
 )
+
+var statusDescription = map[status]string{
+	statusSuccess:               "STATUS_SUCCESS",
+	statusPending:               "STATUS_PENDING",
+	statusInterruptInBusy:       "STATUS_INTERRUPT_IN_BUSY",
+	statusFailed:                "STATUS_FAILED",
+	statusTransferNotInProgress: "STATUS_TRANSFER_NOT_IN_PROGRESS",
+	statusSplitNotInProgress:    "STATUS_SPLIT_NOT_IN_PROGRESS",
+	statusSplitInProgress:       "STATUS_SPLIT_IN_PROGRESS",
+}
+
+func (s status) String() string {
+	if desc, ok := statusDescription[s]; ok {
+		return desc
+	}
+	return fmt.Sprintf("STATUS_0x%02x", byte(s))
+}
+
+// The bmRequestType values used by the USBTMC and USB488 class-specific
+// requests come from Table 15 of the USBTMC Specification 1.0 and Table 9 of
+// the USBTMC-USB488 Specification 1.0. All class-specific requests are
+// device-to-host requests directed either at the USBTMC interface or at one
+// of its bulk endpoints.
+const (
+	requestTypeClassInterfaceIn byte = 0xa1 // Dir=IN, Type=Class, Recipient=Interface
+	requestTypeClassEndpointIn  byte = 0xa2 // Dir=IN, Type=Class, Recipient=Endpoint
+)
@@ -6,6 +6,8 @@
 package usbtmc
 
 import (
+	"fmt"
+
 	"github.com/gotmc/usbtmc/driver"
 )
 
@@ -41,33 +43,160 @@ func (c *Context) SetStartTag(startTag byte) {
 }
 
 // NewDeviceByVIDPID creates new USBTMC compliant device based on the given the
-// vendor ID and product ID. If multiple USB devices matching the VID and PID
-// are found, only the first is returned.
+// vendor ID and product ID using the device's lowest numbered USBTMC
+// interface. If multiple USB devices matching the VID and PID are found, only
+// the first is returned.
 func (c *Context) NewDeviceByVIDPID(VID, PID int) (*Device, error) {
-	d := defaultDevice()
-	d.bTag = c.startTag
-	usbDevice, err := c.libusbContext.NewDeviceByVIDPID(VID, PID)
+	return c.newDeviceByVIDPID(VID, PID, driver.AnyInterface)
+}
+
+func (c *Context) newDeviceByVIDPID(VID, PID, iface int) (*Device, error) {
+	usbDevice, err := c.libusbContext.NewDeviceByVIDPID(VID, PID, iface)
 	if err != nil {
 		return nil, err
 	}
-	d.usbDevice = usbDevice
-	return &d, nil
+	return c.newDevice(usbDevice), nil
+}
+
+// NewDevicesByVIDPID creates a new USBTMC compliant device for every attached
+// device with the given vendor ID and product ID, such as a fixture of
+// identical DUTs, using each device's lowest numbered USBTMC interface. Info
+// tells the devices apart by serial number and bus/port. If any of the devices
+// cannot be opened, the devices already opened are closed and an error is
+// returned.
+func (c *Context) NewDevicesByVIDPID(VID, PID int) ([]*Device, error) {
+	usbDevices, err := c.libusbContext.NewDevicesByVIDPID(VID, PID, driver.AnyInterface)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]*Device, 0, len(usbDevices))
+	for _, usbDevice := range usbDevices {
+		devices = append(devices, c.newDevice(usbDevice))
+	}
+	return devices, nil
+}
+
+// NewDeviceBySerial creates a new USBTMC compliant device based on the given
+// serial number using the device's lowest numbered USBTMC interface.
+func (c *Context) NewDeviceBySerial(sn string) (*Device, error) {
+	return c.newDeviceBySerial(-1, -1, sn, driver.AnyInterface)
+}
+
+// newDeviceBySerial implements NewDeviceBySerial. Unless they are negative,
+// the device's vendor ID and product ID must match VID and PID.
+func (c *Context) newDeviceBySerial(VID, PID int, sn string, iface int) (*Device, error) {
+	usbDevice, err := c.libusbContext.NewDeviceBySerial(VID, PID, sn, iface)
+	if err != nil {
+		return nil, err
+	}
+	return c.newDevice(usbDevice), nil
 }
 
 // NewDevice creates a new USBTMC compliant device based on the given VISA
-// address string.
+// address string. If the address includes a serial number, the device with
+// that serial number as well as the vendor ID and product ID is opened, and an
+// error is returned if no such device is present. For instruments with empty
+// or duplicate serial numbers, a USB port path may be given in place of the
+// serial number, as in "USB0::0x1AB1::0x04CE::path=1-2.3::INSTR", to open the
+// device on that physical port provided it has the vendor ID and product ID.
+// The USBTMC interface given by the address's interface number is used, or the
+// lowest numbered USBTMC interface if the address does not give one, so that
+// composite devices with more than one interface are supported.
 func (c *Context) NewDevice(address string) (*Device, error) {
 	v, err := NewVisaResource(address)
 	if err != nil {
 		return nil, err
 	}
-	return c.NewDeviceByVIDPID(v.manufacturerID, v.modelCode)
+	if v.portPath != "" {
+		return c.newDeviceByPortPath(v.portPath, v.manufacturerID, v.modelCode,
+			v.usbInterface())
+	}
+	if v.serialNumber == "" {
+		return c.newDeviceByVIDPID(v.manufacturerID, v.modelCode, v.usbInterface())
+	}
+	return c.newDeviceBySerial(v.manufacturerID, v.modelCode, v.serialNumber,
+		v.usbInterface())
+}
+
+// NewDeviceByPortPath creates a new USBTMC compliant device for the device
+// attached at the given USB port path, which is the bus number followed by
+// the chain of hub ports as used by Linux sysfs, such as "1-2.3" for port 3
+// of a hub on port 2 of bus 1. The device's lowest numbered USBTMC interface
+// is used.
+func (c *Context) NewDeviceByPortPath(path string) (*Device, error) {
+	return c.newDeviceByPortPath(path, -1, -1, driver.AnyInterface)
+}
+
+// newDeviceByPortPath implements NewDeviceByPortPath. Unless they are
+// negative, the device's vendor ID and product ID must match VID and PID.
+func (c *Context) newDeviceByPortPath(path string, VID, PID, iface int) (*Device, error) {
+	bus, ports, err := driver.ParsePortPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("usbtmc: %w", err)
+	}
+	usbDevice, err := c.libusbContext.NewDeviceByPortPath(bus, ports, iface)
+	if err != nil {
+		return nil, err
+	}
+	info := usbDevice.Info()
+	if (VID >= 0 && info.VendorID != VID) || (PID >= 0 && info.ProductID != PID) {
+		_ = usbDevice.Close()
+		return nil, fmt.Errorf(
+			"usbtmc: device at USB port path %s has VID 0x%04X and PID 0x%04X, want 0x%04X and 0x%04X",
+			path, info.VendorID, info.ProductID, VID, PID)
+	}
+	return c.newDevice(usbDevice), nil
+}
+
+// newDevice wraps the opened usbDevice in a Device using the context's start
+// tag.
+func (c *Context) newDevice(usbDevice driver.USBDevice) *Device {
+	d := defaultDevice()
+	d.bTag = c.startTag
+	d.startTag = c.startTag
+	d.usbDevice = usbDevice
+	return &d
+}
+
+// DeviceInfo describes an attached USBTMC interface found by ListDevices.
+type DeviceInfo struct {
+	driver.DeviceInfo
+	// USB488 reports whether the interface implements the USBTMC-USB488
+	// subclass specification.
+	USB488 bool
+	// Resource is the VISA resource string addressing the interface, such as
+	// "USB0::0x2A8D::0x1102::MY12345::INSTR".
+	Resource string
+}
+
+// ListDevices returns every attached USBTMC interface, identified by the
+// Application Specific interface class and USBTMC subclass codes, without
+// opening any of them for use.
+func (c *Context) ListDevices() ([]DeviceInfo, error) {
+	found, err := c.libusbContext.ListDevices()
+	if err != nil {
+		return nil, fmt.Errorf("usbtmc: listing devices: %w", err)
+	}
+	infos := make([]DeviceInfo, 0, len(found))
+	for _, info := range found {
+		infos = append(infos, newDeviceInfo(info))
+	}
+	return infos, nil
+}
+
+func newDeviceInfo(info driver.DeviceInfo) DeviceInfo {
+	return DeviceInfo{
+		DeviceInfo: info,
+		USB488:     bInterfaceProtocol(info.InterfaceProtocol) == usb488Protocol,
+		Resource:   visaResourceString(info),
+	}
 }
 
 func defaultDevice() Device {
 	return Device{
 		termChar:        '\n',
 		bTag:            1,
+		startTag:        1,
 		termCharEnabled: true,
 	}
 }
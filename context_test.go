@@ -0,0 +1,306 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/gotmc/usbtmc/driver"
+)
+
+// mockContext implements driver.Context for testing.
+type mockContext struct {
+	infos   []driver.DeviceInfo
+	listErr error
+	opened  []string
+	last    *mockUSBDevice // last device opened by port path
+}
+
+func (m *mockContext) Close() error            { return nil }
+func (m *mockContext) SetDebugLevel(level int) {}
+
+func (m *mockContext) NewDeviceByVIDPID(VID, PID, iface int) (driver.USBDevice, error) {
+	m.opened = append(m.opened, fmt.Sprintf("0x%04X:0x%04X", VID, PID))
+	return m.open(iface), nil
+}
+
+func (m *mockContext) NewDevicesByVIDPID(VID, PID, iface int) ([]driver.USBDevice, error) {
+	var devs []driver.USBDevice
+	for _, info := range m.infos {
+		if info.VendorID == VID && info.ProductID == PID {
+			dev := m.open(iface)
+			dev.info = info
+			devs = append(devs, dev)
+		}
+	}
+	if len(devs) == 0 {
+		return nil, fmt.Errorf("no devices found matching VID %v and PID %v", VID, PID)
+	}
+	return devs, nil
+}
+
+func (m *mockContext) NewDeviceByPortPath(bus int, ports []int, iface int) (driver.USBDevice, error) {
+	for _, info := range m.infos {
+		if info.Bus == bus && slices.Equal(info.Path, ports) {
+			m.opened = append(m.opened, info.PortPath())
+			dev := m.open(iface)
+			dev.info = info
+			m.last = dev
+			return dev, nil
+		}
+	}
+	return nil, fmt.Errorf("no device found at USB port path %v-%v", bus, ports)
+}
+
+func (m *mockContext) NewDeviceBySerial(VID, PID int, sn string, iface int) (driver.USBDevice, error) {
+	for _, info := range m.infos {
+		if (VID < 0 || info.VendorID == VID) && (PID < 0 || info.ProductID == PID) &&
+			info.SerialNumber == sn &&
+			(iface == driver.AnyInterface || info.InterfaceNumber == iface) {
+			m.opened = append(m.opened, sn)
+			dev := m.open(iface)
+			dev.info = info
+			m.last = dev
+			return dev, nil
+		}
+	}
+	return nil, fmt.Errorf("no devices found with serial number %q", sn)
+}
+
+// open returns a mock device for the interface selected by iface, using
+// interface 0 for driver.AnyInterface.
+func (m *mockContext) open(iface int) *mockUSBDevice {
+	if iface == driver.AnyInterface {
+		iface = 0
+	}
+	return &mockUSBDevice{iface: iface}
+}
+
+func (m *mockContext) ListDevices() ([]driver.DeviceInfo, error) {
+	return m.infos, m.listErr
+}
+
+func TestListDevices(t *testing.T) {
+	mc := &mockContext{infos: []driver.DeviceInfo{
+		{VendorID: 0x2a8d, ProductID: 0x1102, SerialNumber: "MY12345",
+			InterfaceProtocol: 0x01},
+		{VendorID: 0x1ab1, ProductID: 0x04ce, SerialNumber: "DS1ZA",
+			InterfaceNumber: 1, InterfaceProtocol: 0x00},
+	}}
+	c := &Context{libusbContext: mc, startTag: 1}
+	infos, err := c.ListDevices()
+	if err != nil {
+		t.Fatalf("ListDevices returned error: %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("ListDevices returned %d devices, want 2", len(infos))
+	}
+	if !infos[0].USB488 || infos[1].USB488 {
+		t.Errorf("USB488 = %v, %v, want true, false", infos[0].USB488, infos[1].USB488)
+	}
+	if want := "USB0::0x2A8D::0x1102::MY12345::INSTR"; infos[0].Resource != want {
+		t.Errorf("Resource = %q, want %q", infos[0].Resource, want)
+	}
+	if want := "USB0::0x1AB1::0x04CE::DS1ZA::1::INSTR"; infos[1].Resource != want {
+		t.Errorf("Resource = %q, want %q", infos[1].Resource, want)
+	}
+	if infos[1].SerialNumber != "DS1ZA" {
+		t.Errorf("SerialNumber = %q, want %q", infos[1].SerialNumber, "DS1ZA")
+	}
+}
+
+func TestListDevicesError(t *testing.T) {
+	listErr := errors.New("list failed")
+	c := &Context{libusbContext: &mockContext{listErr: listErr}}
+	if _, err := c.ListDevices(); !errors.Is(err, listErr) {
+		t.Errorf("ListDevices error = %v, want %v", err, listErr)
+	}
+}
+
+func TestNewDeviceMatchesSerial(t *testing.T) {
+	mc := &mockContext{infos: []driver.DeviceInfo{
+		{VendorID: 0x2a8d, ProductID: 0x1102, SerialNumber: "MY11111"},
+		{VendorID: 0x2a8d, ProductID: 0x1102, SerialNumber: "MY12345"},
+		{VendorID: 0x0957, ProductID: 0x0407, SerialNumber: "MY99999"},
+	}}
+	c := &Context{libusbContext: mc, startTag: 3}
+	testCases := []struct {
+		name    string
+		address string
+		opened  string
+		isError bool
+	}{
+		{"serial", "USB0::0x2A8D::0x1102::MY12345::INSTR", "MY12345", false},
+		{"no_serial", "USB0::0x2A8D::0x1102::INSTR", "0x2A8D:0x1102", false},
+		{"missing_serial", "USB0::0x2A8D::0x1102::MY00000::INSTR", "", true},
+		{"serial_of_other_model", "USB0::0x2A8D::0x1102::MY99999::INSTR", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc.opened = nil
+			d, err := c.NewDevice(tc.address)
+			if tc.isError {
+				if err == nil {
+					t.Fatalf("NewDevice(%q) returned no error, opened %v", tc.address, mc.opened)
+				}
+				if len(mc.opened) != 0 {
+					t.Errorf("opened %v, want none", mc.opened)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewDevice(%q) returned error: %v", tc.address, err)
+			}
+			if len(mc.opened) != 1 || mc.opened[0] != tc.opened {
+				t.Errorf("opened %v, want [%s]", mc.opened, tc.opened)
+			}
+			if d.bTag != 3 || d.startTag != 3 {
+				t.Errorf("bTag = %d, startTag = %d, want 3", d.bTag, d.startTag)
+			}
+		})
+	}
+}
+
+func TestNewDeviceDuplicateSerial(t *testing.T) {
+	// Two models share a serial number, and the other model comes first.
+	mc := &mockContext{
+		infos: []driver.DeviceInfo{
+			{VendorID: 0x0957, ProductID: 0x0407, SerialNumber: "MY12345"},
+			{VendorID: 0x2a8d, ProductID: 0x1102, SerialNumber: "MY12345"},
+		},
+		// NewDevice must not depend on enumerating every device.
+		listErr: errors.New("mock: listing devices"),
+	}
+	c := &Context{libusbContext: mc}
+	d, err := c.NewDevice("USB0::0x2A8D::0x1102::MY12345::INSTR")
+	if err != nil {
+		t.Fatalf("NewDevice returned error: %v", err)
+	}
+	if info := d.Info(); info.VendorID != 0x2a8d || info.ProductID != 0x1102 {
+		t.Errorf("opened VID 0x%04X PID 0x%04X, want 0x2A8D 0x1102",
+			info.VendorID, info.ProductID)
+	}
+}
+
+func TestNewDeviceSelectsInterface(t *testing.T) {
+	mc := &mockContext{infos: []driver.DeviceInfo{
+		{VendorID: 0x2a8d, ProductID: 0x1102, SerialNumber: "MY12345", InterfaceNumber: 2},
+	}}
+	c := &Context{libusbContext: mc, startTag: 1}
+	testCases := []struct {
+		name    string
+		address string
+		iface   int
+		isError bool
+	}{
+		{"serial_interface", "USB0::0x2A8D::0x1102::MY12345::2::INSTR", 2, false},
+		{"serial_no_interface", "USB0::0x2A8D::0x1102::MY12345::INSTR", 0, false},
+		{"wrong_interface", "USB0::0x2A8D::0x1102::MY12345::1::INSTR", 0, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := c.NewDevice(tc.address)
+			if tc.isError {
+				if err == nil {
+					t.Fatalf("NewDevice(%q) returned no error", tc.address)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewDevice(%q) returned error: %v", tc.address, err)
+			}
+			if got := d.InterfaceNumber(); got != tc.iface {
+				t.Errorf("InterfaceNumber = %d, want %d", got, tc.iface)
+			}
+		})
+	}
+}
+
+func TestNewDevicesByVIDPID(t *testing.T) {
+	mc := &mockContext{infos: []driver.DeviceInfo{
+		{VendorID: 0x2a8d, ProductID: 0x1102, SerialNumber: "MY11111", Bus: 1, Path: []int{2}},
+		{VendorID: 0x0957, ProductID: 0x0407, SerialNumber: "MY99999", Bus: 1, Path: []int{3}},
+		{VendorID: 0x2a8d, ProductID: 0x1102, SerialNumber: "MY22222", Bus: 2, Path: []int{1, 4}},
+	}}
+	c := &Context{libusbContext: mc, startTag: 5}
+	devs, err := c.NewDevicesByVIDPID(0x2a8d, 0x1102)
+	if err != nil {
+		t.Fatalf("NewDevicesByVIDPID returned error: %v", err)
+	}
+	if len(devs) != 2 {
+		t.Fatalf("NewDevicesByVIDPID returned %d devices, want 2", len(devs))
+	}
+	wantSerials := []string{"MY11111", "MY22222"}
+	for i, d := range devs {
+		info := d.Info()
+		if info.SerialNumber != wantSerials[i] {
+			t.Errorf("device %d SerialNumber = %q, want %q", i, info.SerialNumber, wantSerials[i])
+		}
+		want := "USB0::0x2A8D::0x1102::" + wantSerials[i] + "::INSTR"
+		if info.Resource != want {
+			t.Errorf("device %d Resource = %q, want %q", i, info.Resource, want)
+		}
+		if d.startTag != 5 {
+			t.Errorf("device %d startTag = %d, want 5", i, d.startTag)
+		}
+	}
+	if got := devs[1].Info().Path; len(got) != 2 || got[0] != 1 || got[1] != 4 {
+		t.Errorf("device 1 Path = %v, want [1 4]", got)
+	}
+
+	if _, err := c.NewDevicesByVIDPID(0x1ab1, 0x04ce); err == nil {
+		t.Error("NewDevicesByVIDPID with no matching devices returned no error")
+	}
+}
+
+func TestNewDeviceByPortPath(t *testing.T) {
+	mc := &mockContext{infos: []driver.DeviceInfo{
+		{VendorID: 0x1ab1, ProductID: 0x04ce, Bus: 1, Path: []int{2, 3}},
+		{VendorID: 0x1ab1, ProductID: 0x04ce, Bus: 1, Path: []int{2, 4}},
+	}}
+	c := &Context{libusbContext: mc, startTag: 1}
+
+	d, err := c.NewDeviceByPortPath("1-2.4")
+	if err != nil {
+		t.Fatalf("NewDeviceByPortPath returned error: %v", err)
+	}
+	if got := d.Info().PortPath(); got != "1-2.4" {
+		t.Errorf("PortPath = %q, want %q", got, "1-2.4")
+	}
+	if _, err := c.NewDeviceByPortPath("1-2"); err == nil {
+		t.Error("NewDeviceByPortPath with no device at the path returned no error")
+	}
+	if _, err := c.NewDeviceByPortPath("1.2"); err == nil {
+		t.Error("NewDeviceByPortPath with an invalid path returned no error")
+	}
+}
+
+func TestNewDeviceMatchesPortPath(t *testing.T) {
+	mc := &mockContext{infos: []driver.DeviceInfo{
+		{VendorID: 0x1ab1, ProductID: 0x04ce, Bus: 1, Path: []int{2, 3}},
+		{VendorID: 0x1ab1, ProductID: 0x04ce, Bus: 1, Path: []int{2, 4}},
+	}}
+	c := &Context{libusbContext: mc, startTag: 1}
+
+	d, err := c.NewDevice("USB0::0x1AB1::0x04CE::path=1-2.3::INSTR")
+	if err != nil {
+		t.Fatalf("NewDevice returned error: %v", err)
+	}
+	if got := d.Info().PortPath(); got != "1-2.3" {
+		t.Errorf("PortPath = %q, want %q", got, "1-2.3")
+	}
+
+	_, err = c.NewDevice("USB0::0x2A8D::0x1102::path=1-2.4::INSTR")
+	if err == nil {
+		t.Fatal("NewDevice with a VID and PID not matching the device at the path returned no error")
+	}
+	if !mc.last.closed {
+		t.Error("device with a mismatched VID and PID was not closed")
+	}
+}
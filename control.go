@@ -0,0 +1,181 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotmc/usbtmc/driver"
+)
+
+// controlPollInterval is how long to wait between status checks while a
+// class-specific request reports STATUS_PENDING.
+var controlPollInterval = 10 * time.Millisecond
+
+// StatusError is returned when a USBTMC or USB488 class-specific control
+// request completes with a USBTMC_status other than STATUS_SUCCESS. It
+// matches the sentinel error for its status, such as ErrStatusPending or
+// ErrStatusFailed, when tested with errors.Is.
+type StatusError struct {
+	// Request is the bRequest value of the class-specific request.
+	Request uint8
+	// Status is the USBTMC_status value the device returned.
+	Status uint8
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("usbtmc: request %d returned %s (%s)",
+		e.Request, status(e.Status), bRequest(e.Request))
+}
+
+// Is reports whether target is the sentinel error for e's USBTMC_status.
+func (e *StatusError) Is(target error) bool {
+	sentinel, ok := statusErrors[status(e.Status)]
+	return ok && target == sentinel
+}
+
+// Sentinel errors for the USBTMC_status values a class-specific request can
+// return, for use with errors.Is. STATUS_PENDING and STATUS_INTERRUPT_IN_BUSY
+// mean the device is busy and the request can be retried, while the others
+// mean the request failed.
+var (
+	ErrStatusPending         = errors.New("usbtmc: " + statusPending.String())
+	ErrInterruptInBusy       = errors.New("usbtmc: " + statusInterruptInBusy.String())
+	ErrStatusFailed          = errors.New("usbtmc: " + statusFailed.String())
+	ErrTransferNotInProgress = errors.New("usbtmc: " + statusTransferNotInProgress.String())
+	ErrSplitNotInProgress    = errors.New("usbtmc: " + statusSplitNotInProgress.String())
+	ErrSplitInProgress       = errors.New("usbtmc: " + statusSplitInProgress.String())
+)
+
+var statusErrors = map[status]error{
+	statusPending:               ErrStatusPending,
+	statusInterruptInBusy:       ErrInterruptInBusy,
+	statusFailed:                ErrStatusFailed,
+	statusTransferNotInProgress: ErrTransferNotInProgress,
+	statusSplitNotInProgress:    ErrSplitNotInProgress,
+	statusSplitInProgress:       ErrSplitInProgress,
+}
+
+// controlIn sends the given class-specific request to the USBTMC interface and
+// returns the length bytes of the response. The first byte of every USBTMC
+// response is the USBTMC_status, which is checked against STATUS_SUCCESS.
+func (d *Device) controlIn(
+	ctx context.Context,
+	req bRequest,
+	wValue uint16,
+	length int,
+) ([]byte, error) {
+	return d.classRequest(ctx, requestTypeClassInterfaceIn, req, wValue,
+		uint16(d.usbDevice.InterfaceNumber()), length) //nolint:gosec
+}
+
+// controlEndpointIn is like controlIn but directs the class-specific request
+// to the bulk endpoint with the given address, as required by the abort
+// requests.
+func (d *Device) controlEndpointIn(
+	ctx context.Context,
+	req bRequest,
+	wValue uint16,
+	endpoint uint8,
+	length int,
+) ([]byte, error) {
+	return d.classRequest(ctx, requestTypeClassEndpointIn, req, wValue,
+		uint16(endpoint), length)
+}
+
+func (d *Device) classRequest(
+	ctx context.Context,
+	bmRequestType byte,
+	req bRequest,
+	wValue, wIndex uint16,
+	length int,
+) ([]byte, error) {
+	resp := make([]byte, length)
+	n, err := d.usbDevice.ControlContext(ctx, bmRequestType, uint8(req), wValue, wIndex, resp)
+	if err != nil {
+		return nil, fmt.Errorf("usbtmc: request %d failed: %w", req, err)
+	}
+	debug.Printf("control request %d response %s\n",
+		req, hex.EncodeToString(resp[:n]))
+	if n < 1 {
+		return nil, fmt.Errorf("usbtmc: request %d returned no data", req)
+	}
+	if s := status(resp[0]); s != statusSuccess {
+		return resp[:n], &StatusError{Request: uint8(req), Status: uint8(s)}
+	}
+	if n < length {
+		return nil, fmt.Errorf(
+			"usbtmc: short response to request %d: got %d bytes, want %d",
+			req, n, length)
+	}
+	return resp, nil
+}
+
+// clearHalt clears the halt condition of the endpoint with the given
+// address.
+func (d *Device) clearHalt(ctx context.Context, endpoint uint8) error {
+	if err := d.usbDevice.ClearHalt(ctx, endpoint); err != nil {
+		return fmt.Errorf("usbtmc: clearing halt on endpoint %#02x: %w",
+			endpoint, err)
+	}
+	return nil
+}
+
+// recoverStall clears the halt on the endpoint with the given address when
+// err shows that a transfer on it failed because the endpoint stalled, so
+// that the following transfers can succeed. It returns err, joined with any
+// error from clearing the halt.
+func (d *Device) recoverStall(ctx context.Context, endpoint uint8, err error) error {
+	if !errors.Is(err, driver.ErrStall) {
+		return err
+	}
+	debug.Printf("endpoint %#02x stalled; clearing halt\n", endpoint)
+	hctx, cancel := abortContext(ctx)
+	defer cancel()
+	if herr := d.clearHalt(hctx, endpoint); herr != nil {
+		return errors.Join(err, herr)
+	}
+	return err
+}
+
+// drainBulkIn reads and discards data from the Bulk-IN endpoint until the
+// device sends a short packet, as the USBTMC specification requires before
+// checking the status of a clear or abort.
+func (d *Device) drainBulkIn(ctx context.Context) error {
+	buf := make([]byte, d.bulkInPacketSize())
+	for {
+		n, err := d.usbDevice.ReadContext(ctx, buf)
+		if errors.Is(err, driver.ErrStall) {
+			// A halted endpoint has nothing left to drain once the halt is
+			// cleared.
+			return d.clearHalt(ctx, d.usbDevice.BulkInEndpointAddress())
+		}
+		if err != nil {
+			return err
+		}
+		debug.Printf("drained %d bytes from Bulk-IN\n", n)
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
+
+// pollWait waits controlPollInterval before the next status check, or returns
+// early if the context is done.
+func pollWait(ctx context.Context) error {
+	t := time.NewTimer(controlPollInterval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
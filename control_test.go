@@ -0,0 +1,110 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gotmc/usbtmc/driver"
+)
+
+func TestStatusErrorIs(t *testing.T) {
+	tests := []struct {
+		status byte
+		want   error
+	}{
+		{0x02, ErrStatusPending},
+		{0x20, ErrInterruptInBusy},
+		{0x80, ErrStatusFailed},
+		{0x81, ErrTransferNotInProgress},
+		{0x82, ErrSplitNotInProgress},
+		{0x83, ErrSplitInProgress},
+	}
+	sentinels := []error{
+		ErrStatusPending,
+		ErrInterruptInBusy,
+		ErrStatusFailed,
+		ErrTransferNotInProgress,
+		ErrSplitNotInProgress,
+		ErrSplitInProgress,
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.Error(), func(t *testing.T) {
+			mock := &mockUSBDevice{}
+			mock.reply(initiateClear, tt.status)
+			dev := newTestDevice(mock)
+
+			err := dev.Clear(context.Background())
+			var se *StatusError
+			if !errors.As(err, &se) {
+				t.Fatalf("error = %v, want *StatusError", err)
+			}
+			if se.Request != uint8(initiateClear) || se.Status != tt.status {
+				t.Errorf("StatusError = %+v, want request %d status %#02x",
+					se, initiateClear, tt.status)
+			}
+			for _, sentinel := range sentinels {
+				if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+					t.Errorf("errors.Is(err, %v) = %t", sentinel, got)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusErrorUnknownStatus(t *testing.T) {
+	err := &StatusError{Request: uint8(getCapabilities), Status: 0x40}
+	if errors.Is(err, ErrStatusFailed) || errors.Is(err, ErrStatusPending) {
+		t.Errorf("%v matched a sentinel error", err)
+	}
+	want := "usbtmc: request 7 returned STATUS_0x40 " +
+		"(Returns attributes and capabilities of the USBTMC interface.)"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestReadStallRecovery(t *testing.T) {
+	mock := &mockUSBDevice{halted: map[uint8]bool{0x81: true}}
+	dev := newTestDevice(mock)
+	mock.reads = [][]byte{buildDevDepMsgInResponse(2, []byte("+1.0\n"))}
+	buf := make([]byte, 100)
+
+	_, err := dev.ReadBinary(context.Background(), buf)
+	if !errors.Is(err, driver.ErrStall) {
+		t.Fatalf("error = %v, want driver.ErrStall", err)
+	}
+	if len(mock.controls) != 1 || mock.controls[0].bRequest != requestClearFeature ||
+		mock.controls[0].wIndex != 0x81 {
+		t.Fatalf("control transfers = %+v, want CLEAR_FEATURE on 0x81", mock.controls)
+	}
+
+	n, err := dev.ReadBinary(context.Background(), buf)
+	if err != nil {
+		t.Fatalf("ReadBinary after clearing halt returned error: %v", err)
+	}
+	if string(buf[:n]) != "+1.0\n" {
+		t.Errorf("ReadBinary = %q, want %q", buf[:n], "+1.0\n")
+	}
+}
+
+func TestWriteStallRecovery(t *testing.T) {
+	mock := &mockUSBDevice{halted: map[uint8]bool{0x02: true}}
+	dev := newTestDevice(mock)
+
+	_, err := dev.WriteBinary(context.Background(), []byte("*RST\n"))
+	if !errors.Is(err, driver.ErrStall) {
+		t.Fatalf("error = %v, want driver.ErrStall", err)
+	}
+	if len(mock.controls) != 1 || mock.controls[0].wIndex != 0x02 {
+		t.Fatalf("control transfers = %+v, want CLEAR_FEATURE on 0x02", mock.controls)
+	}
+	if _, err := dev.WriteBinary(context.Background(), []byte("*RST\n")); err != nil {
+		t.Errorf("WriteBinary after clearing halt returned error: %v", err)
+	}
+}
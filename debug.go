@@ -13,10 +13,14 @@ const (
 
 var (
 	debug *log.Logger
+	// debugEnabled guards debug output whose arguments are costly to build,
+	// so that the read and write paths do not allocate when debugging is off.
+	debugEnabled bool
 )
 
 func init() {
 	if os.Getenv(debugEnv) != "" {
+		debugEnabled = true
 		debug = log.New(os.Stderr, debugPrefix, log.LstdFlags)
 	} else {
 		debug = log.New(io.Discard, "", 0)
@@ -6,10 +6,10 @@
 package usbtmc
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -18,11 +18,10 @@ import (
 )
 
 const (
-	// This is a guess. The USB spec says the max value can be fetched from
-	// the descriptor, but the libusb documentation says packets can be up
-	// to 512 bytes.
-	// Ref: https://libusb.sourceforge.io/api-1.0/libusb_packetoverflow.html
-	maxPacketSize = 512
+	// defaultMaxPacketSize is the bulk endpoint packet size assumed when the
+	// driver cannot report the endpoint's wMaxPacketSize. It is the packet
+	// size of a high-speed bulk endpoint.
+	defaultMaxPacketSize = 512
 
 	usbtmcHeaderLen = 12
 )
@@ -33,8 +32,24 @@ type Device struct {
 	mu              sync.Mutex
 	usbDevice       driver.USBDevice
 	bTag            byte
+	startTag        byte
+	statusTag       byte
 	termChar        byte
 	termCharEnabled bool
+	truncation      TruncationPolicy
+	pending         []byte
+	pendingID       msgID
+	pendingAttr     byte
+	maxTransferSize int
+	maxMessageSize  int
+	wbuf            []byte // reused Bulk-OUT transfer buffer
+	rbuf            []byte // reused Bulk-IN transfer buffer
+	cmdBuf          []byte // reused Command buffer
+	queryBuf        []byte // reused Query response buffer
+	triggerFallback bool
+	caps            *Capabilities
+	intr            *interruptReader
+	closed          bool
 }
 
 // Write creates the appropriate USBMTC header, writes the header and data on
@@ -46,17 +61,42 @@ func (d *Device) Write(p []byte) (n int, err error) {
 
 // WriteBinary writes binary data without adding a terminator. It creates the
 // appropriate USBTMC header, writes the header and data on the bulk out
-// endpoint, and returns the number of bytes written and any errors.
+// endpoint, and returns the number of bytes written and any errors. If ctx is
+// cancelled part way through the message, the current Bulk-OUT transfer is
+// aborted on the device and an *AbortError reporting the number of bytes the
+// device accepted is returned.
 func (d *Device) WriteBinary(ctx context.Context, p []byte) (n int, err error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	// FIXME(mdr): I need to change this so that I look at the size of the buf
-	// being written to see if it can truly fit into one transfer, and if not
-	// split it into multiple transfers.
-	maxTransferSize := 512
+	return d.writeBinary(ctx, p)
+}
+
+// writeBinary implements WriteBinary. The caller must hold d.mu.
+func (d *Device) writeBinary(ctx context.Context, p []byte) (n int, err error) {
+	return d.writeTransfers(ctx, p, encodeBulkOutHeader)
+}
+
+// headerEncoder encodes the Bulk-OUT header for one transfer of a message.
+type headerEncoder func(bTag byte, transferSize uint32, eom bool) [12]byte
+
+// writeTransfers splits p into as many Bulk-OUT transfers as needed, each
+// with a header created by encode, and writes them to the bulk out endpoint.
+// The caller must hold d.mu.
+func (d *Device) writeTransfers(
+	ctx context.Context,
+	p []byte,
+	encode headerEncoder,
+) (n int, err error) {
+	d.discardPending()
+	maxTransferSize := d.transferSize()
 	for pos := 0; pos < len(p); {
 		if err := ctx.Err(); err != nil {
-			return pos, err
+			if pos == 0 {
+				return 0, err
+			}
+			// The previous transfer completed without EOM, so the device is
+			// holding a partial message.
+			return d.abortWrite(ctx, err, d.bTag, pos, 0, false, false)
 		}
 		d.bTag = nextbTag(d.bTag)
 		thisLen := len(p[pos:])
@@ -64,38 +104,113 @@ func (d *Device) WriteBinary(ctx context.Context, p []byte) (n int, err error) {
 			thisLen = maxTransferSize - bulkOutHeaderSize
 		}
 		isLastChunk := pos+thisLen >= len(p)
-		header := encodeBulkOutHeader(d.bTag, uint32(thisLen), isLastChunk)
-		data := append(header[:], p[pos:pos+thisLen]...)
-		if moduloFour := len(data) % 4; moduloFour > 0 {
-			numAlignment := 4 - moduloFour
-			alignment := bytes.Repeat([]byte{0x00}, numAlignment)
-			data = append(data, alignment...)
+		header := encode(d.bTag, uint32(thisLen), isLastChunk) //nolint:gosec
+		// Each transfer is padded with zeros to a multiple of 4 bytes.
+		size := bulkOutHeaderSize + thisLen
+		if moduloFour := size % 4; moduloFour > 0 {
+			size += 4 - moduloFour
 		}
+		data := growBuffer(&d.wbuf, size)
+		copy(data, header[:])
+		copied := copy(data[bulkOutHeaderSize:], p[pos:pos+thisLen])
+		clear(data[bulkOutHeaderSize+copied:])
 		_, err := d.usbDevice.WriteContext(ctx, data)
 		if err != nil {
-			return pos, err
+			if timedOut(ctx, err) {
+				return d.abortWrite(ctx, errors.Join(ctx.Err(), err), d.bTag,
+					pos, thisLen, true, isLastChunk)
+			}
+			return pos, d.recoverStall(ctx, d.usbDevice.BulkOutEndpointAddress(), err)
 		}
 		pos += thisLen
 	}
 	return len(p), nil
 }
 
+// SetMaxTransferSize sets the maximum size in bytes of each Bulk-OUT
+// transfer used to write a message, including the 12-byte header and the
+// alignment bytes. Messages larger than one transfer are split into several
+// transfers, each with its own header. The size is rounded down to a multiple
+// of the Bulk-OUT endpoint's wMaxPacketSize, but is never less than the number
+// of whole packets needed for the header and four message bytes. A size of
+// zero or less restores the default of 1 MB.
+func (d *Device) SetMaxTransferSize(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxTransferSize = n
+}
+
+// transferSize returns the maximum Bulk-OUT transfer size aligned to the
+// Bulk-OUT endpoint's wMaxPacketSize. The caller must hold d.mu.
+func (d *Device) transferSize() int {
+	packetSize := d.usbDevice.BulkOutMaxPacketSize()
+	if packetSize <= 0 {
+		packetSize = defaultMaxPacketSize
+	}
+	n := d.maxTransferSize
+	if n <= 0 {
+		n = ioBufferSize
+	}
+	// Each transfer must hold the header and at least one 4-byte aligned
+	// group of message bytes, which takes two packets on an 8-byte endpoint.
+	minSize := bulkOutHeaderSize + 4
+	minSize += (packetSize - minSize%packetSize) % packetSize
+	return max(n-n%packetSize, minSize)
+}
+
 // doRead creates and sends the header on the bulk out endpoint and then reads
-// from the bulk in endpoint per USBTMC standard.
+// from the bulk in endpoint per USBTMC standard. If ctx is cancelled or its
+// deadline passes once the request has been sent, the pending Bulk-IN
+// transfer is aborted on the device and an *AbortError is returned.
 func (d *Device) doRead(ctx context.Context, p []byte, useTermChar bool) (n int, err error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	defer d.releaseBuffers()
+	n, _, err = d.readTransfer(ctx, p, requestDevDepMsgIn,
+		useTermChar && d.termCharEnabled, d.termChar)
+	return n, err
+}
+
+// readTransfer sends the Bulk-OUT request header for the given msgID, which
+// is either REQUEST_DEV_DEP_MSG_IN or REQUEST_VENDOR_SPECIFIC_IN, and then
+// reads the device's response into p. For REQUEST_DEV_DEP_MSG_IN, the device
+// is asked to end the transfer after termChar if termCharEnabled is set. Per
+// USBTMC Table 2, each response msgID has the same value as its request
+// msgID. Along with the number of bytes read, it returns the
+// bmTransferAttributes of the response. The caller must hold d.mu.
+func (d *Device) readTransfer(
+	ctx context.Context,
+	p []byte,
+	id msgID,
+	termCharEnabled bool,
+	termChar byte,
+) (n int, transferAttr byte, err error) {
 	if err := ctx.Err(); err != nil {
-		return 0, err
+		return 0, 0, err
+	}
+	if len(d.pending) > 0 && d.pendingID == id {
+		return d.readPending(p)
 	}
 	d.bTag = nextbTag(d.bTag)
-	header := encodeMsgInBulkOutHeader(d.bTag, uint32(len(p)), //nolint:gosec
-		useTermChar && d.termCharEnabled, d.termChar)
-	if _, err = d.usbDevice.WriteContext(ctx, header[:]); err != nil {
-		return 0, err
+	var header [12]byte
+	if id == requestVendorSpecificIn {
+		header = encodeRequestVendorSpecificInHeader(d.bTag, uint32(len(p))) //nolint:gosec
+	} else {
+		header = encodeMsgInBulkOutHeader(d.bTag, uint32(len(p)), //nolint:gosec
+			termCharEnabled, termChar)
+	}
+	req := growBuffer(&d.wbuf, bulkOutHeaderSize)
+	copy(req, header[:])
+	if _, err = d.usbDevice.WriteContext(ctx, req); err != nil {
+		if timedOut(ctx, err) {
+			return 0, 0, d.abortRequest(ctx, errors.Join(ctx.Err(), err), d.bTag)
+		}
+		return 0, 0, d.recoverStall(ctx, d.usbDevice.BulkOutEndpointAddress(), err)
+	}
+	if debugEnabled {
+		debug.Printf("sent msgID %d request hdr %v (data len %v)\n",
+			id, hex.EncodeToString(header[:]), len(p))
 	}
-	debug.Printf("sent reqdevdepmsgin hdr %v (data len %v)\n",
-		hex.EncodeToString(header[:]), len(p))
 
 	// Per Figure 4 in the USBTMC spec, messages may be sent in multiple
 	// transfers. The first will have a USBTMC header, the middle transfers
@@ -107,53 +222,79 @@ func (d *Device) doRead(ctx context.Context, p []byte, useTermChar bool) (n int,
 	//      the USBTMC header)
 	//   3) the number of bytes in the current transfer (resp).
 	//
-	// The header also includes an end-of-message (EOM) bit, but it's not
-	// clear how this bit is used.
+	// The header also includes an end-of-message (EOM) bit, which is
+	// returned in transferAttr so that ReadMessage can tell whether the
+	// message continues in another transfer.
 	//
 	// We'll attempt to read the number of bytes the caller wants (1), but
 	// will stop short if the number of bytes the device wants to send (2)
 	// is reached or if it sends a transfer with zero non-header bytes.
 	pos := 0
 	var transfer int
+	var extra []byte
 	for pos < len(p) {
 		if err := ctx.Err(); err != nil {
-			return pos, err
+			n, err = d.abortRead(ctx, err, d.bTag, pos)
+			return n, transferAttr, err
 		}
 		var resp int
 		var err error
 		if pos == 0 {
-			resp, transfer, _, err = d.readRemoveHeader(ctx, d.bTag, p[pos:])
+			resp, transfer, transferAttr, extra, err = d.readRemoveHeader(
+				ctx, id, d.bTag, p[pos:])
 		} else {
-			resp, err = d.readKeepHeader(ctx, p[pos:])
+			resp, extra, err = d.readKeepHeader(ctx, p[pos:])
 		}
-		debug.Printf("read: pos %d (buf left %d); got %d bytes",
-			pos, len(p[pos:]), resp)
-
-		dumpLen, dumpTrunc := 100, 1
-		if resp < dumpLen {
-			dumpLen, dumpTrunc = resp, 0
-		}
-		if left := len(p) - pos; left < dumpLen {
-			dumpLen, dumpTrunc = left, 0
+		if debugEnabled {
+			debug.Printf("read: pos %d (buf left %d); got %d bytes",
+				pos, len(p[pos:]), resp)
+
+			dumpLen, dumpTrunc := 100, 1
+			if resp < dumpLen {
+				dumpLen, dumpTrunc = resp, 0
+			}
+			if left := len(p) - pos; left < dumpLen {
+				dumpLen, dumpTrunc = left, 0
+			}
+			debug.Printf("data[%d:]=%s%s\n", pos,
+				hex.EncodeToString(p[pos:pos+dumpLen]),
+				[]string{"", "..."}[dumpTrunc])
 		}
-		debug.Printf("data[%d:]=%s%s\n", pos,
-			hex.EncodeToString(p[pos:pos+dumpLen]),
-			[]string{"", "..."}[dumpTrunc])
 
 		if err != nil {
-			return pos, err
+			if timedOut(ctx, err) {
+				n, err = d.abortRead(ctx, errors.Join(ctx.Err(), err), d.bTag, pos)
+				return n, transferAttr, err
+			}
+			return pos, transferAttr,
+				d.recoverStall(ctx, d.usbDevice.BulkInEndpointAddress(), err)
 		}
 		if resp == 0 {
 			debug.Print("zero-length read; giving up")
 			break
 		}
 		pos += resp
-		if pos >= transfer {
+		if pos >= transfer || len(extra) > 0 {
 			break
 		}
 	}
 
-	return min(pos, transfer), nil
+	// A device that sends more than the caller's buffer holds leaves the
+	// rest of the transfer behind, which the next read would mistake for a
+	// response header.
+	if pos == len(p) && transfer > pos {
+		remaining, err := d.readRemainder(ctx, extra, transfer-pos)
+		if err != nil {
+			if timedOut(ctx, err) {
+				n, err = d.abortRead(ctx, errors.Join(ctx.Err(), err), d.bTag, pos)
+				return n, transferAttr, err
+			}
+			return pos, transferAttr,
+				d.recoverStall(ctx, d.usbDevice.BulkInEndpointAddress(), err)
+		}
+		return pos, transferAttr, d.truncated(ctx, id, pos, remaining, transferAttr)
+	}
+	return min(pos, transfer), transferAttr, nil
 }
 
 // Read reads from the device respecting the termChar setting. Use for transfers
@@ -167,6 +308,25 @@ func (d *Device) ReadBinary(ctx context.Context, p []byte) (n int, err error) {
 	return d.doRead(ctx, p, false)
 }
 
+// WriteVendorSpecific writes p to the device in VENDOR_SPECIFIC_OUT messages,
+// which carry vendor-specific data such as firmware or calibration images
+// rather than device-dependent messages.
+func (d *Device) WriteVendorSpecific(ctx context.Context, p []byte) (n int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writeTransfers(ctx, p, encodeVendorSpecificOutHeader)
+}
+
+// ReadVendorSpecific sends a REQUEST_VENDOR_SPECIFIC_IN message for up to len(p)
+// bytes and reads the device's VENDOR_SPECIFIC_IN response into p.
+func (d *Device) ReadVendorSpecific(ctx context.Context, p []byte) (n int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.releaseBuffers()
+	n, _, err = d.readTransfer(ctx, p, requestVendorSpecificIn, false, 0)
+	return n, err
+}
+
 // ReadRaw reads from the device without allowing termChar to be set. Use for
 // transfers of binary data.
 func (d *Device) ReadRaw(p []byte) (n int, err error) {
@@ -210,55 +370,60 @@ func inHdrToString(buf []byte) string {
 	return out
 }
 
+// readRemoveHeader reads the first packets of a Bulk-IN transfer, validates
+// its header, and copies as much of the data as fits into p. Any data that
+// arrived beyond len(p) is returned in extra.
 func (d *Device) readRemoveHeader(
-	ctx context.Context, expectedBTag byte, p []byte,
-) (n int, transfer int, transferAttr byte, err error) {
+	ctx context.Context, expectedMsgID msgID, expectedBTag byte, p []byte,
+) (n int, transfer int, transferAttr byte, extra []byte, err error) {
 	// Reading from the USB device triggers interactions with the hardware,
 	// so we take care with the buffer size. The caller expects len(p)
 	// bytes, but we also need to allow space for the USBTMC header. The
 	// libusb documentation is full of dire warnings about what happens if
 	// the incoming data exceeds the receiving buffer[^1]. It recommends
 	// making sure the incoming buffer is a multiple of the maximum packet
-	// size. We don't know the actual maximum packet size, but we think we
-	// know the maximum packet size, so rounding the transfer size up to the
-	// next multiple of the maximum packet size should make it difficult for
-	// incoming data to overflow.
+	// size, so rounding the transfer size up to the next multiple of the
+	// Bulk-IN endpoint's wMaxPacketSize keeps incoming data from overflowing.
 	//
 	// [^1]: https://libusb.sourceforge.io/api-1.0/libusb_packetoverflow.html
+	packetSize := d.bulkInPacketSize()
 	tempSz := len(p) + usbtmcHeaderLen
-	if m := tempSz % 512; m != 0 {
-		tempSz += 512 - m
+	if m := tempSz % packetSize; m != 0 {
+		tempSz += packetSize - m
 	}
 
-	debug.Printf("readRemoveHeader: len(p) %v, w/hdr %v -> buf size %v\n",
-		len(p), len(p)+usbtmcHeaderLen, tempSz)
-	temp := make([]byte, tempSz)
+	if debugEnabled {
+		debug.Printf("readRemoveHeader: len(p) %v, w/hdr %v -> buf size %v\n",
+			len(p), len(p)+usbtmcHeaderLen, tempSz)
+	}
+	temp := growBuffer(&d.rbuf, tempSz)
 
 	n, err = d.usbDevice.ReadContext(ctx, temp)
 	if err != nil {
-		return 0, 0, 0, err
+		return 0, 0, 0, nil, err
 	}
 	if n < usbtmcHeaderLen {
-		return 0, 0, 0, fmt.Errorf(
+		return 0, 0, 0, nil, fmt.Errorf(
 			"short %d-byte read: no space for header", n)
 	}
 
-	debug.Printf("readRemoveHeader: header %s\n", inHdrToString(temp))
+	if debugEnabled {
+		debug.Printf("readRemoveHeader: header %s\n", inHdrToString(temp))
+	}
 
 	// Validate the response header per USBTMC Table 5.
 	respMsgID := msgID(temp[0])
-	if respMsgID != devDepMsgIn {
-		return 0, 0, 0, fmt.Errorf(
-			"unexpected MsgID: got %d, want %d (DEV_DEP_MSG_IN)",
-			respMsgID, devDepMsgIn)
+	if respMsgID != expectedMsgID {
+		return 0, 0, 0, nil, fmt.Errorf(
+			"unexpected MsgID: got %d, want %d", respMsgID, expectedMsgID)
 	}
 	respBTag := temp[1]
 	if respBTag != expectedBTag {
-		return 0, 0, 0, fmt.Errorf(
+		return 0, 0, 0, nil, fmt.Errorf(
 			"bTag mismatch: got %d, want %d", respBTag, expectedBTag)
 	}
 	if temp[2] != invertbTag(respBTag) {
-		return 0, 0, 0, fmt.Errorf(
+		return 0, 0, 0, nil, fmt.Errorf(
 			"bTagInverse mismatch: got %d, want %d",
 			temp[2], invertbTag(respBTag))
 	}
@@ -267,23 +432,65 @@ func (d *Device) readRemoveHeader(
 	transfer = int(t32)
 	transferAttr = temp[8]
 
-	// Copy the bytes after the reader to the caller's buffer, but only as
+	// Copy the bytes after the header to the caller's buffer, but only as
 	// many bytes as the USB device said it read. Let the caller deal with
 	// any discrepancies between the USBTMC transfer size and the number of
 	// bytes we got from the USB device.
-	toCopy := min(len(temp)-usbtmcHeaderLen, n-usbtmcHeaderLen)
-	if toCopy > 0 {
-		copy(p, temp[usbtmcHeaderLen:usbtmcHeaderLen+toCopy])
+	data := temp[usbtmcHeaderLen:n]
+	n = copy(p, data)
+	return n, transfer, transferAttr, data[n:], nil
+}
+
+// readKeepHeader reads the following packets of a Bulk-IN transfer, which
+// carry no header, into p. As with readRemoveHeader, the read buffer is
+// rounded up to a multiple of the Bulk-IN endpoint's wMaxPacketSize and any
+// data that arrived beyond len(p) is returned in extra.
+func (d *Device) readKeepHeader(
+	ctx context.Context, p []byte,
+) (n int, extra []byte, err error) {
+	packetSize := d.bulkInPacketSize()
+	if len(p)%packetSize == 0 {
+		n, err = d.usbDevice.ReadContext(ctx, p)
+		return n, nil, err
 	}
-	return n - usbtmcHeaderLen, transfer, transferAttr, nil
+	temp := growBuffer(&d.rbuf, len(p)+packetSize-len(p)%packetSize)
+	n, err = d.usbDevice.ReadContext(ctx, temp)
+	if err != nil {
+		return 0, nil, err
+	}
+	data := temp[:n]
+	n = copy(p, data)
+	return n, data[n:], nil
 }
 
-func (d *Device) readKeepHeader(ctx context.Context, p []byte) (n int, err error) {
-	return d.usbDevice.ReadContext(ctx, p)
+// Info returns the DeviceInfo describing the device, including its serial
+// number, bus/port location and VISA resource string.
+func (d *Device) Info() DeviceInfo {
+	return newDeviceInfo(d.usbDevice.Info())
 }
 
-// Close closes the underlying USB device.
+// InterfaceNumber returns the bInterfaceNumber of the USBTMC interface used
+// by the device, which matters for composite devices that have other
+// interfaces, such as HID or mass storage, alongside the USBTMC interface.
+func (d *Device) InterfaceNumber() int {
+	return d.usbDevice.InterfaceNumber()
+}
+
+// Close closes the underlying USB device, stopping the background
+// Interrupt-IN reader if one is running. The USB drivers do not allow a device
+// to be closed while a transfer is running on it, so Close first waits for any
+// I/O in progress to finish, which is bounded by the I/O's context or the
+// driver's timeout. Close also waits for an open MessageWriter or
+// MessageReader to be closed, so it must not be called from the goroutine
+// using one.
 func (d *Device) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.intr != nil {
+		d.intr.stop()
+		d.intr = nil
+	}
+	d.closed = true
 	return d.usbDevice.Close()
 }
 
@@ -301,30 +508,93 @@ func (d *Device) WriteStringContext(ctx context.Context, s string) (n int, err e
 // Command sends the SCPI/ASCII command to the underlying USB device. A newline
 // character is automatically added to the end of the string.
 func (d *Device) Command(ctx context.Context, format string, a ...any) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.releaseBuffers()
+	return d.command(ctx, format, a...)
+}
+
+// command implements Command, building the command in a buffer that is
+// reused from call to call. The caller must hold d.mu.
+func (d *Device) command(ctx context.Context, format string, a ...any) error {
 	cmd := format
 	if a != nil {
 		cmd = fmt.Sprintf(format, a...)
 	}
-	_, err := d.WriteStringContext(ctx, strings.TrimSpace(cmd)+string(d.termChar))
+	d.cmdBuf = append(d.cmdBuf[:0], strings.TrimSpace(cmd)...)
+	d.cmdBuf = append(d.cmdBuf, d.termChar)
+	_, err := d.writeBinary(ctx, d.cmdBuf)
 	return err
 }
 
+// growBuffer returns a slice of length n backed by *buf, replacing *buf with a
+// larger buffer when it is too small.
+func growBuffer(buf *[]byte, n int) []byte {
+	if cap(*buf) < n {
+		*buf = make([]byte, n)
+	}
+	return (*buf)[:n]
+}
+
+// maxKeptBufferSize is the largest reusable buffer a Device keeps once a call
+// returns. Larger buffers, such as those grown to read a long message, are
+// released so that a single large transfer does not pin its memory for the
+// lifetime of the Device.
+const maxKeptBufferSize = 64 * 1024
+
+// releaseBuffers drops the reusable read, command, and Query buffers that
+// have grown beyond maxKeptBufferSize. The Bulk-OUT buffer is kept, since its
+// size is bounded by SetMaxTransferSize. The caller must hold d.mu.
+func (d *Device) releaseBuffers() {
+	for _, buf := range []*[]byte{&d.rbuf, &d.cmdBuf, &d.queryBuf} {
+		if cap(*buf) > maxKeptBufferSize {
+			*buf = nil
+		}
+	}
+}
+
+// bulkInPacketSize returns the wMaxPacketSize of the Bulk-IN endpoint, or
+// defaultMaxPacketSize if the driver does not report it.
+func (d *Device) bulkInPacketSize() int {
+	if n := d.usbDevice.BulkInMaxPacketSize(); n > 0 {
+		return n
+	}
+	return defaultMaxPacketSize
+}
+
 // Query writes the given string to the USBTMC device and returns the returned
 // value as a string. A newline character is automatically added to the query
-// command sent to the instrument.
+// command sent to the instrument. Query holds the Device for the whole
+// exchange, so concurrent queries cannot read each other's responses, and
+// reads the response until EOM, ignoring the termChar, so it works for short
+// replies and long ones such as binary waveform data alike. Query allocates
+// only the returned string; to read replies without allocating, send the
+// query with Command and read the reply into a reused buffer with ReadBinary.
 func (d *Device) Query(ctx context.Context, s string) (string, error) {
-	err := d.Command(ctx, s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.releaseBuffers()
+	resp, err := d.query(ctx, s)
 	if err != nil {
 		return "", err
 	}
+	return string(resp), nil
+}
+
+// query implements Query. The returned response is only valid until the next
+// query. The caller must hold d.mu.
+func (d *Device) query(ctx context.Context, s string) ([]byte, error) {
+	if err := d.command(ctx, s); err != nil {
+		return nil, err
+	}
 
-	// Try to ensure a single-packet read using ASCII mode (with termChar).
-	p := make([]byte, maxPacketSize-usbtmcHeaderLen)
-	n, err := d.doRead(ctx, p, true)
+	// Most replies fit in a single packet, so ask for that much first. The
+	// termChar is not used, since a binary block in the reply may contain it.
+	resp, err := d.readMessage(ctx, d.queryBuf[:0],
+		d.bulkInPacketSize()-usbtmcHeaderLen, ReadOptions{})
+	d.queryBuf = resp
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	s = string(p[:n])
-	p = nil
-	return s, nil
+	return resp, nil
 }
@@ -6,18 +6,53 @@
 package usbtmc
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/gotmc/usbtmc/driver"
 )
 
+// requestClearFeature is the standard CLEAR_FEATURE bRequest, which the mock
+// records for each ClearHalt call.
+const requestClearFeature uint8 = 0x01
+
 // mockUSBDevice records writes and replays reads for testing.
 type mockUSBDevice struct {
-	writes [][]byte // captured raw writes
-	reads  [][]byte // queued responses to return from Read
-	readN  int      // index into reads
-	closed bool
+	writes   [][]byte               // captured raw writes
+	reads    [][]byte               // queued responses to return from Read
+	readN    int                    // index into reads
+	readLens []int                  // buffer length of each read
+	onWrite  func(i int) error      // optional hook called before write i
+	onRead   func(i int) error      // optional hook called before read i
+	notifies chan []byte            // queued Interrupt-IN notifications
+	hasIntr  bool                   // whether the mock has an Interrupt-IN endpoint
+	controls []controlCall          // captured control transfers
+	onCtrl   func(c controlCall)    // optional hook called for each control transfer
+	replies  map[uint8][][]byte     // queued control responses keyed by bRequest
+	packet   int                    // wMaxPacketSize of the bulk endpoints, 0 if unknown
+	discard  bool                   // count transfers without recording them
+	respond  func(bTag byte) []byte // optional responder replacing reads
+	lastTag  byte                   // bTag of the last Bulk-OUT header written
+	writeN   int                    // number of writes
+	halted   map[uint8]bool         // endpoints that stall until their halt is cleared
+	iface    int                    // bInterfaceNumber of the USBTMC interface
+	info     driver.DeviceInfo      // returned by Info
+	closed   bool
+}
+
+// controlCall records the setup packet of a control transfer.
+type controlCall struct {
+	bmRequestType uint8
+	bRequest      uint8
+	wValue        uint16
+	wIndex        uint16
+	wLength       int
 }
 
 func (m *mockUSBDevice) Write(p []byte) (int, error) {
@@ -25,6 +60,21 @@ func (m *mockUSBDevice) Write(p []byte) (int, error) {
 }
 
 func (m *mockUSBDevice) WriteContext(_ context.Context, p []byte) (int, error) {
+	if m.halted[m.BulkOutEndpointAddress()] {
+		return 0, fmt.Errorf("mock: write: %w", driver.ErrStall)
+	}
+	if m.onWrite != nil {
+		if err := m.onWrite(len(m.writes)); err != nil {
+			return 0, err
+		}
+	}
+	m.writeN++
+	if len(p) > 1 {
+		m.lastTag = p[1]
+	}
+	if m.discard {
+		return len(p), nil
+	}
 	cp := make([]byte, len(p))
 	copy(cp, p)
 	m.writes = append(m.writes, cp)
@@ -40,6 +90,20 @@ func (m *mockUSBDevice) Read(p []byte) (int, error) {
 }
 
 func (m *mockUSBDevice) ReadContext(_ context.Context, p []byte) (int, error) {
+	if !m.discard {
+		m.readLens = append(m.readLens, len(p))
+	}
+	if m.halted[m.BulkInEndpointAddress()] {
+		return 0, fmt.Errorf("mock: read: %w", driver.ErrStall)
+	}
+	if m.onRead != nil {
+		if err := m.onRead(m.readN); err != nil {
+			return 0, err
+		}
+	}
+	if m.respond != nil {
+		return copy(p, m.respond(m.lastTag)), nil
+	}
 	if m.readN >= len(m.reads) {
 		return 0, errors.New("mock: no more reads queued")
 	}
@@ -49,6 +113,106 @@ func (m *mockUSBDevice) ReadContext(_ context.Context, p []byte) (int, error) {
 	return n, nil
 }
 
+func (m *mockUSBDevice) ControlContext(
+	_ context.Context,
+	bmRequestType, bRequest uint8,
+	wValue, wIndex uint16,
+	data []byte,
+) (int, error) {
+	c := controlCall{bmRequestType, bRequest, wValue, wIndex, len(data)}
+	m.controls = append(m.controls, c)
+	if m.onCtrl != nil {
+		m.onCtrl(c)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	queue := m.replies[bRequest]
+	if len(queue) == 0 {
+		return 0, fmt.Errorf("mock: no reply queued for request %d", bRequest)
+	}
+	m.replies[bRequest] = queue[1:]
+	return copy(data, queue[0]), nil
+}
+
+func (m *mockUSBDevice) ClearHalt(_ context.Context, endpoint uint8) error {
+	m.controls = append(m.controls,
+		controlCall{0x02, requestClearFeature, 0, uint16(endpoint), 0})
+	delete(m.halted, endpoint)
+	return nil
+}
+
+// reply queues a control transfer response for the given request.
+func (m *mockUSBDevice) reply(req bRequest, resp ...byte) {
+	if m.replies == nil {
+		m.replies = make(map[uint8][][]byte)
+	}
+	m.replies[uint8(req)] = append(m.replies[uint8(req)], resp)
+}
+
+// requests returns the bRequest of each control transfer the mock received.
+func (m *mockUSBDevice) requests() []uint8 {
+	reqs := make([]uint8, len(m.controls))
+	for i, c := range m.controls {
+		reqs[i] = c.bRequest
+	}
+	return reqs
+}
+
+func equalRequests(got []uint8, want ...uint8) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *mockUSBDevice) Info() driver.DeviceInfo {
+	return m.info
+}
+
+func (m *mockUSBDevice) InterfaceNumber() int {
+	return m.iface
+}
+
+func (m *mockUSBDevice) BulkInEndpointAddress() uint8 {
+	return 0x81
+}
+
+func (m *mockUSBDevice) BulkOutEndpointAddress() uint8 {
+	return 0x02
+}
+
+func (m *mockUSBDevice) BulkInMaxPacketSize() int {
+	return m.packet
+}
+
+func (m *mockUSBDevice) BulkOutMaxPacketSize() int {
+	return m.packet
+}
+
+func (m *mockUSBDevice) HasInterruptIn() bool {
+	return m.hasIntr
+}
+
+func (m *mockUSBDevice) ReadInterruptContext(ctx context.Context, p []byte) (int, error) {
+	select {
+	case data := <-m.notifies:
+		return copy(p, data), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// notify queues an Interrupt-IN notification.
+func (m *mockUSBDevice) notify(bNotify1, bNotify2 byte) {
+	m.notifies <- []byte{bNotify1, bNotify2}
+}
+
 func (m *mockUSBDevice) Close() error {
 	m.closed = true
 	return nil
@@ -129,6 +293,7 @@ func TestWriteMultiChunk(t *testing.T) {
 	mock := &mockUSBDevice{}
 	dev := newTestDevice(mock)
 
+	dev.SetMaxTransferSize(512)
 	// Create data larger than maxTransferSize - bulkOutHeaderSize (500 bytes).
 	data := make([]byte, 600)
 	for i := range data {
@@ -155,6 +320,80 @@ func TestWriteMultiChunk(t *testing.T) {
 	}
 }
 
+func TestWriteDefaultTransferSize(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+
+	data := make([]byte, 2*ioBufferSize)
+	if _, err := dev.WriteBinary(context.Background(), data); err != nil {
+		t.Fatalf("WriteBinary returned error: %v", err)
+	}
+	if len(mock.writes) != 3 {
+		t.Fatalf("expected 3 USB writes, got %d", len(mock.writes))
+	}
+	if got := len(mock.writes[0]); got != ioBufferSize {
+		t.Errorf("first transfer = %d bytes, want %d", got, ioBufferSize)
+	}
+	want := uint32(ioBufferSize - bulkOutHeaderSize)
+	if size := binary.LittleEndian.Uint32(mock.writes[0][4:8]); size != want {
+		t.Errorf("first transfer size = %d, want %d", size, want)
+	}
+}
+
+func TestSetMaxTransferSize(t *testing.T) {
+	tests := []struct {
+		packet  int
+		size    int
+		payload int
+	}{
+		{64, 100, 52},
+		{64, 10, 52},
+		{8, 8, 4},
+		{8, 20, 4},
+		{8, 24, 12},
+		{64, 256, 244},
+		{512, 1000, 500},
+		{1024, 3000, 2036},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("packet%d_size%d", tt.packet, tt.size), func(t *testing.T) {
+			mock := &mockUSBDevice{packet: tt.packet}
+			dev := newTestDevice(mock)
+			dev.SetMaxTransferSize(tt.size)
+
+			if _, err := dev.WriteBinary(context.Background(), make([]byte, 5000)); err != nil {
+				t.Fatalf("WriteBinary returned error: %v", err)
+			}
+			w := mock.writes[0]
+			if size := binary.LittleEndian.Uint32(w[4:8]); int(size) != tt.payload {
+				t.Errorf("transfer size = %d, want %d", size, tt.payload)
+			}
+			if len(w)%tt.packet != 0 {
+				t.Errorf("transfer of %d bytes is not a multiple of %d", len(w), tt.packet)
+			}
+		})
+	}
+}
+
+func BenchmarkWriteBinary(b *testing.B) {
+	data := make([]byte, 16*1024*1024)
+	for _, size := range []int{512, 64 * 1024, ioBufferSize} {
+		b.Run(fmt.Sprintf("transfer%d", size), func(b *testing.B) {
+			mock := &mockUSBDevice{discard: true}
+			dev := newTestDevice(mock)
+			dev.SetMaxTransferSize(size)
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := dev.WriteBinary(context.Background(), data); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(mock.writeN)/float64(b.N), "transfers/op")
+		})
+	}
+}
+
 func TestReadSingleTransfer(t *testing.T) {
 	mock := &mockUSBDevice{}
 	dev := newTestDevice(mock)
@@ -254,6 +493,30 @@ func TestQuery(t *testing.T) {
 	}
 }
 
+func TestQueryIgnoresTermChar(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+
+	// The binary block contains the termChar, and the device marks the end
+	// of the first transfer with the TermChar attribute but not EOM.
+	first := buildDevDepMsgInResponse(2, []byte("#15ab\n"))
+	first[8] = transferAttrTermChar
+	mock.reads = [][]byte{first, buildDevDepMsgInResponse(3, []byte("cd\n"))}
+
+	result, err := dev.Query(context.Background(), "CURV?")
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if want := "#15ab\ncd\n"; result != want {
+		t.Errorf("Query result = %q, want %q", result, want)
+	}
+	for i, w := range mock.writes[1:] {
+		if w[8]&transferAttrTermChar != 0 {
+			t.Errorf("request %d asked the device to end on the termChar", i)
+		}
+	}
+}
+
 func TestWriteBinaryCancellation(t *testing.T) {
 	mock := &mockUSBDevice{}
 	dev := newTestDevice(mock)
@@ -328,6 +591,339 @@ func TestReadWrongMsgID(t *testing.T) {
 	}
 }
 
+func TestWriteVendorSpecific(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+
+	dev.SetMaxTransferSize(512)
+	data := make([]byte, 600)
+	n, err := dev.WriteVendorSpecific(context.Background(), data)
+	if err != nil {
+		t.Fatalf("WriteVendorSpecific returned error: %v", err)
+	}
+	if n != len(data) {
+		t.Errorf("WriteVendorSpecific returned n=%d, want %d", n, len(data))
+	}
+	if len(mock.writes) != 2 {
+		t.Fatalf("expected 2 USB writes, got %d", len(mock.writes))
+	}
+	for i, w := range mock.writes {
+		if w[0] != byte(vendorSpecificOut) {
+			t.Errorf("write %d msgID = %d, want %d", i, w[0], vendorSpecificOut)
+		}
+		if w[8] != 0x00 {
+			t.Errorf("write %d reserved byte 8 = %d, want 0", i, w[8])
+		}
+		if len(w)%4 != 0 {
+			t.Errorf("write %d length %d is not 4-byte aligned", i, len(w))
+		}
+	}
+	if size := binary.LittleEndian.Uint32(mock.writes[1][4:8]); size != 100 {
+		t.Errorf("second transfer size = %d, want 100", size)
+	}
+}
+
+func TestQueryLongResponse(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	curve := make([]byte, 1200)
+	for i := range curve {
+		curve[i] = byte('0' + i%10)
+	}
+	// The first request asks for a single packet's worth of data, and the
+	// device continues the message in the next transfer.
+	mock.reads = [][]byte{
+		buildPartialMsgInResponse(2, curve[:500]),
+		buildDevDepMsgInResponse(3, curve[500:]),
+	}
+
+	result, err := dev.Query(context.Background(), "CURV?")
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if result != string(curve) {
+		t.Errorf("Query returned %d bytes, want %d", len(result), len(curve))
+	}
+	if size := binary.LittleEndian.Uint32(mock.writes[1][4:8]); size != 500 {
+		t.Errorf("first request transfer size = %d, want 500", size)
+	}
+	if size := binary.LittleEndian.Uint32(mock.writes[2][4:8]); size != readMessageChunk {
+		t.Errorf("second request transfer size = %d, want %d", size, readMessageChunk)
+	}
+}
+
+func TestQueryConcurrent(t *testing.T) {
+	mock := &mockUSBDevice{}
+	// The mock answers each request with the last command it received.
+	mock.respond = func(bTag byte) []byte {
+		for i := len(mock.writes) - 1; i >= 0; i-- {
+			if w := mock.writes[i]; w[0] == byte(devDepMsgOut) {
+				size := binary.LittleEndian.Uint32(w[4:8])
+				return buildDevDepMsgInResponse(bTag, w[bulkOutHeaderSize:bulkOutHeaderSize+size])
+			}
+		}
+		return nil
+	}
+	dev := newTestDevice(mock)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				cmd := fmt.Sprintf("MEAS%d:VOLT%d?", g, i)
+				result, err := dev.Query(context.Background(), cmd)
+				if err != nil {
+					t.Errorf("Query returned error: %v", err)
+					return
+				}
+				if result != cmd+"\n" {
+					t.Errorf("Query(%q) = %q", cmd, result)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// newQueryBenchDevice returns a Device whose mock answers every request with
+// a DEV_DEP_MSG_IN response carrying payload, without allocating.
+func newQueryBenchDevice(payload []byte) (*Device, *mockUSBDevice) {
+	var responses [256][]byte
+	for i := range responses {
+		responses[i] = buildDevDepMsgInResponse(byte(i), payload)
+	}
+	mock := &mockUSBDevice{
+		discard: true,
+		respond: func(bTag byte) []byte { return responses[bTag] },
+	}
+	return newTestDevice(mock), mock
+}
+
+func TestCommandAllocs(t *testing.T) {
+	dev, _ := newQueryBenchDevice(nil)
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := dev.Command(ctx, "VOLT 1.5"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Command made %v allocations, want 0", allocs)
+	}
+}
+
+func TestReadBinaryAllocs(t *testing.T) {
+	dev, _ := newQueryBenchDevice(make([]byte, 4000))
+	ctx := context.Background()
+	buf := make([]byte, 4096)
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := dev.Command(ctx, "CURV?"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dev.ReadBinary(ctx, buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Command and ReadBinary made %v allocations, want 0", allocs)
+	}
+}
+
+func TestQueryAllocs(t *testing.T) {
+	dev, _ := newQueryBenchDevice([]byte("+1.23456789E+00\n"))
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := dev.Query(ctx, "READ?"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 1 {
+		t.Errorf("Query made %v allocations, want 1", allocs)
+	}
+}
+
+func TestLargeReplyReleasesBuffers(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 4*maxKeptBufferSize)
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx := context.Background()
+	// The Query command takes bTag 1. Its first request asks for a packet's
+	// worth of the reply and the second for the rest, and ReadMessage then
+	// reads the whole payload again in a single transfer.
+	first := defaultMaxPacketSize - usbtmcHeaderLen
+	mock.reads = [][]byte{
+		buildPartialMsgInResponse(2, payload[:first]),
+		buildDevDepMsgInResponse(3, payload[first:]),
+		buildDevDepMsgInResponse(4, payload),
+	}
+
+	resp, err := dev.Query(ctx, "DISP:DATA?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != len(payload) {
+		t.Fatalf("Query returned %d bytes, want %d", len(resp), len(payload))
+	}
+	if n := cap(dev.queryBuf); n > maxKeptBufferSize {
+		t.Errorf("Query kept a %d byte response buffer", n)
+	}
+	if n := cap(dev.rbuf); n > maxKeptBufferSize {
+		t.Errorf("Query kept a %d byte read buffer", n)
+	}
+
+	if _, err := dev.ReadMessage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := cap(dev.rbuf); n > maxKeptBufferSize {
+		t.Errorf("ReadMessage kept a %d byte read buffer", n)
+	}
+}
+
+// BenchmarkQuery measures a steady-state Query, whose only allocation is the
+// returned string.
+func BenchmarkQuery(b *testing.B) {
+	dev, _ := newQueryBenchDevice([]byte("+1.23456789E+00\n"))
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := dev.Query(ctx, "READ?"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCommand(b *testing.B) {
+	dev, _ := newQueryBenchDevice(nil)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := dev.Command(ctx, "VOLT 1.5"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadBinary(b *testing.B) {
+	dev, _ := newQueryBenchDevice(make([]byte, 4000))
+	ctx := context.Background()
+	buf := make([]byte, 4096)
+	b.ReportAllocs()
+	b.SetBytes(4000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := dev.WriteBinary(ctx, []byte("CURV?\n")); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := dev.ReadBinary(ctx, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReadRoundsToMaxPacketSize(t *testing.T) {
+	tests := []struct {
+		packet  int
+		bufLen  int
+		readLen int
+	}{
+		{0, 100, 512},
+		{64, 100, 128},
+		{64, 52, 64},
+		{512, 600, 1024},
+		{1024, 100, 1024},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("packet%d_buf%d", tt.packet, tt.bufLen), func(t *testing.T) {
+			mock := &mockUSBDevice{packet: tt.packet}
+			dev := newTestDevice(mock)
+			mock.reads = [][]byte{buildDevDepMsgInResponse(1, []byte("data"))}
+
+			if _, err := dev.ReadBinary(context.Background(), make([]byte, tt.bufLen)); err != nil {
+				t.Fatalf("ReadBinary returned error: %v", err)
+			}
+			if got := mock.readLens[0]; got != tt.readLen {
+				t.Errorf("read buffer = %d bytes, want %d", got, tt.readLen)
+			}
+		})
+	}
+}
+
+func TestQueryFullSpeed(t *testing.T) {
+	mock := &mockUSBDevice{packet: 64}
+	dev := newTestDevice(mock)
+	mock.reads = [][]byte{buildDevDepMsgInResponse(2, []byte("1.0\n"))}
+
+	if _, err := dev.Query(context.Background(), "VOLT?"); err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	// The request asks for one 64-byte packet less the USBTMC header.
+	if size := binary.LittleEndian.Uint32(mock.writes[1][4:8]); size != 52 {
+		t.Errorf("request transfer size = %d, want 52", size)
+	}
+	if got := mock.readLens[0]; got != 64 {
+		t.Errorf("read buffer = %d bytes, want 64", got)
+	}
+}
+
+func TestReadVendorSpecific(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+
+	payload := []byte{0xde, 0xad, 0xbe, 0xef, 0x01}
+	resp := buildDevDepMsgInResponse(1, payload)
+	resp[0] = byte(vendorSpecificIn)
+	resp[8] = 0x00
+	mock.reads = [][]byte{resp}
+
+	buf := make([]byte, 100)
+	n, err := dev.ReadVendorSpecific(context.Background(), buf)
+	if err != nil {
+		t.Fatalf("ReadVendorSpecific returned error: %v", err)
+	}
+	if string(buf[:n]) != string(payload) {
+		t.Errorf("ReadVendorSpecific data = %x, want %x", buf[:n], payload)
+	}
+	w := mock.writes[0]
+	if w[0] != byte(requestVendorSpecificIn) {
+		t.Errorf("request msgID = %d, want %d", w[0], requestVendorSpecificIn)
+	}
+	if size := binary.LittleEndian.Uint32(w[4:8]); size != uint32(len(buf)) {
+		t.Errorf("request transfer size = %d, want %d", size, len(buf))
+	}
+}
+
+func TestReadVendorSpecificValidatesHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(resp []byte)
+		want   string
+	}{
+		{"dev_dep_msg_in", func(resp []byte) { resp[0] = byte(devDepMsgIn) }, "unexpected MsgID"},
+		{"bTag", func(resp []byte) { resp[1] = 7 }, "bTag mismatch"},
+		{"bTagInverse", func(resp []byte) { resp[2] = 0 }, "bTagInverse mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockUSBDevice{}
+			dev := newTestDevice(mock)
+			resp := buildDevDepMsgInResponse(1, []byte("data"))
+			resp[0] = byte(vendorSpecificIn)
+			tt.modify(resp)
+			mock.reads = [][]byte{resp}
+
+			_, err := dev.ReadVendorSpecific(context.Background(), make([]byte, 100))
+			if err == nil || !contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %s error", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestClose(t *testing.T) {
 	mock := &mockUSBDevice{}
 	dev := newTestDevice(mock)
@@ -341,6 +937,35 @@ func TestClose(t *testing.T) {
 	}
 }
 
+func TestCloseWaitsForMessageWriter(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+
+	// The USB device must not be closed while a stream still uses it.
+	w := dev.NewMessageWriter(context.Background())
+	closed := make(chan error, 1)
+	go func() { closed <- dev.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while the MessageWriter was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("MessageWriter.Close returned error: %v", err)
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked after the MessageWriter was closed")
+	}
+	if !mock.closed {
+		t.Error("expected underlying USB device to be closed")
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && searchString(s, substr)
 }
@@ -5,7 +5,29 @@
 
 package driver
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrStall is wrapped by the errors drivers return when a transfer fails
+// because the endpoint is halted (stalled). The halt persists until it is
+// cleared with ClearHalt.
+var ErrStall = errors.New("usb endpoint stalled")
+
+// ErrTimeout is wrapped by the errors drivers return when a transfer times
+// out. Drivers that derive the transfer timeout from the context's deadline
+// can time out just before the deadline passes, while the context is still
+// live, so callers must not rely on the context's error alone.
+var ErrTimeout = errors.New("usb transfer timed out")
+
+// AnyInterface may be passed as the interface number when opening a device to
+// claim the lowest numbered interface whose class and subclass codes identify
+// it as a USBTMC interface.
+const AnyInterface = -1
 
 // Driver defines the behavior required by types that want
 // to implement a USBTMC driver.
@@ -17,8 +39,84 @@ type Driver interface {
 type Context interface {
 	Close() error
 	SetDebugLevel(level int)
-	NewDeviceByVIDPID(VID, PID int) (USBDevice, error)
-	// NewDeviceBySerial(sn string) (USBDevice, error)
+	// NewDeviceByVIDPID opens the first device with the given vendor ID and
+	// product ID, claiming the USBTMC interface with the number iface.
+	NewDeviceByVIDPID(VID, PID, iface int) (USBDevice, error)
+	// ListDevices returns a DeviceInfo for every interface of the attached
+	// devices whose class is Application Specific (0xFE) and whose subclass
+	// is USBTMC (0x03).
+	ListDevices() ([]DeviceInfo, error)
+	// NewDevicesByVIDPID opens every device with the given vendor ID and
+	// product ID, claiming the USBTMC interface with the number iface on
+	// each. If any device cannot be opened, the devices already opened are
+	// closed and an error is returned.
+	NewDevicesByVIDPID(VID, PID, iface int) ([]USBDevice, error)
+	// NewDeviceByPortPath opens the device on the given bus whose chain of
+	// hub ports from the root hub is ports, claiming the USBTMC interface
+	// with the number iface.
+	NewDeviceByPortPath(bus int, ports []int, iface int) (USBDevice, error)
+	// NewDeviceBySerial opens the USBTMC device with the given serial
+	// number, claiming the USBTMC interface with the number iface. Unless
+	// they are negative, the device's vendor ID and product ID must also
+	// match VID and PID, since serial numbers are only unique per model.
+	NewDeviceBySerial(VID, PID int, sn string, iface int) (USBDevice, error)
+}
+
+// DeviceInfo describes a USBTMC interface found by ListDevices or claimed by
+// an opened USBDevice.
+type DeviceInfo struct {
+	VendorID     int
+	ProductID    int
+	SerialNumber string
+	Manufacturer string
+	Product      string
+	// Bus and Address locate the device on the host. Path is the chain of
+	// hub ports from the root hub to the device. Drivers that cannot report
+	// the full chain report only the port on the device's parent hub.
+	Bus     int
+	Address int
+	Path    []int
+	// InterfaceNumber is the bInterfaceNumber of the USBTMC interface.
+	InterfaceNumber int
+	// InterfaceProtocol is the bInterfaceProtocol of the USBTMC interface,
+	// which is 0x00 for USBTMC and 0x01 for USBTMC-USB488.
+	InterfaceProtocol uint8
+}
+
+// PortPath returns the bus number and chain of hub ports locating the device
+// in the form used by Linux sysfs, such as "1-2.3" for port 3 of a hub on
+// port 2 of bus 1. An empty string is returned if the path is unknown.
+func (info DeviceInfo) PortPath() string {
+	if len(info.Path) == 0 {
+		return ""
+	}
+	ports := make([]string, len(info.Path))
+	for i, port := range info.Path {
+		ports[i] = strconv.Itoa(port)
+	}
+	return strconv.Itoa(info.Bus) + "-" + strings.Join(ports, ".")
+}
+
+// ParsePortPath parses a port path of the form returned by
+// DeviceInfo.PortPath, such as "1-2.3", into the bus number and the chain of
+// hub ports.
+func ParsePortPath(s string) (bus int, ports []int, err error) {
+	busStr, portStr, ok := strings.Cut(s, "-")
+	if !ok || portStr == "" {
+		return 0, nil, fmt.Errorf("invalid USB port path %q", s)
+	}
+	bus, err = strconv.Atoi(busStr)
+	if err != nil || bus < 0 {
+		return 0, nil, fmt.Errorf("invalid bus number in USB port path %q", s)
+	}
+	for _, p := range strings.Split(portStr, ".") {
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 {
+			return 0, nil, fmt.Errorf("invalid port number in USB port path %q", s)
+		}
+		ports = append(ports, port)
+	}
+	return bus, ports, nil
 }
 
 // USBDevice defines the behavior for a USB device.
@@ -30,4 +128,43 @@ type USBDevice interface {
 	Read(p []byte) (n int, err error)
 	ReadContext(ctx context.Context, p []byte) (n int, err error)
 	WriteContext(ctx context.Context, p []byte) (n int, err error)
+	// ControlContext performs a control transfer on the default control
+	// endpoint using the given setup packet fields. For device-to-host
+	// requests, data receives the response and its length is used as wLength.
+	ControlContext(
+		ctx context.Context,
+		bmRequestType, bRequest uint8,
+		wValue, wIndex uint16,
+		data []byte,
+	) (n int, err error)
+	// ClearHalt clears the halt condition of the endpoint with the given
+	// address using the standard CLEAR_FEATURE(ENDPOINT_HALT) request. Only
+	// the device's side of the endpoint is reset: neither driver can call
+	// libusb_clear_halt, so the host controller's data toggle is not reset
+	// and some host controllers, such as xHCI, may drop the first transfer
+	// after the halt is cleared.
+	ClearHalt(ctx context.Context, endpoint uint8) error
+	// Info returns the DeviceInfo describing the device and its claimed
+	// USBTMC interface.
+	Info() DeviceInfo
+	// InterfaceNumber returns the bInterfaceNumber of the claimed USBTMC
+	// interface, which is the wIndex of the USBTMC class-specific requests
+	// directed at the interface.
+	InterfaceNumber() int
+	// BulkInEndpointAddress returns the address of the Bulk-IN endpoint.
+	BulkInEndpointAddress() uint8
+	// BulkOutEndpointAddress returns the address of the Bulk-OUT endpoint.
+	BulkOutEndpointAddress() uint8
+	// BulkInMaxPacketSize returns the wMaxPacketSize of the Bulk-IN endpoint,
+	// or 0 if it is unknown.
+	BulkInMaxPacketSize() int
+	// BulkOutMaxPacketSize returns the wMaxPacketSize of the Bulk-OUT
+	// endpoint, or 0 if it is unknown.
+	BulkOutMaxPacketSize() int
+	// HasInterruptIn reports whether the USBTMC interface has an Interrupt-IN
+	// endpoint, which USB488 interfaces use for notifications.
+	HasInterruptIn() bool
+	// ReadInterruptContext reads a notification from the Interrupt-IN
+	// endpoint.
+	ReadInterruptContext(ctx context.Context, p []byte) (n int, err error)
 }
@@ -7,6 +7,7 @@ package google
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/gousb"
@@ -14,6 +15,10 @@ import (
 	"github.com/gotmc/usbtmc/driver"
 )
 
+// usbtmcSubClass is the USBTMC interface subclass code per Table 43 of the
+// USBTMC Specification 1.0. The interface class is gousb.ClassApplication.
+const usbtmcSubClass gousb.Class = 0x03
+
 // Driver implements the visa.Driver interface.
 type Driver struct {
 }
@@ -45,9 +50,11 @@ func (c *Context) Close() error {
 }
 
 // NewDeviceByVIDPID creates new USB device based on the given the vendor ID
-// and product ID. If multiple USB devices matching the VID and PID are found,
-// only the first is returned.
-func (c *Context) NewDeviceByVIDPID(VID, PID int) (driver.USBDevice, error) {
+// and product ID, claiming the USBTMC interface with the number iface, or the
+// lowest numbered USBTMC interface if iface is driver.AnyInterface. If
+// multiple USB devices matching the VID and PID are found, only the first is
+// returned.
+func (c *Context) NewDeviceByVIDPID(VID, PID, iface int) (driver.USBDevice, error) {
 	// Iterate through available devices. Find all devices that match the given
 	// Vendor ID and Product ID.
 	vid, usbtmcPID := gousb.ID(uint16(VID)), gousb.ID(uint16(PID)) //nolint:gosec
@@ -133,68 +140,187 @@ func (c *Context) NewDeviceByVIDPID(VID, PID int) (driver.USBDevice, error) {
 	}
 
 	// Pick the first device found.
-	dev := devs[0]
+	return newDevice(devs[0], iface)
+}
 
-	// Switch to configuration #0
-	activeConfig, err := dev.ActiveConfigNum()
+// NewDevicesByVIDPID creates a new USB device for every device matching the
+// given vendor ID and product ID, claiming the interface selected by iface as
+// for NewDeviceByVIDPID. If any device cannot be opened, the devices already
+// opened are closed and an error is returned. Unlike NewDeviceByVIDPID, no
+// attempt is made to take Keysight USB modular devices out of boot mode.
+func (c *Context) NewDevicesByVIDPID(VID, PID, iface int) ([]driver.USBDevice, error) {
+	vid, pid := gousb.ID(uint16(VID)), gousb.ID(uint16(PID)) //nolint:gosec
+	devs, err := c.ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		return desc.Vendor == vid && desc.Product == pid
+	})
 	if err != nil {
+		for _, d := range devs {
+			_ = d.Close()
+		}
 		return nil, err
 	}
-	cfg, err := dev.Config(activeConfig)
-	if err != nil {
-		return nil, err
+	if len(devs) == 0 {
+		return nil, fmt.Errorf("no devices found matching VID %s and PID %s", vid, pid)
 	}
-	var bulkIn *gousb.InEndpoint
-	var bulkOut *gousb.OutEndpoint
-	var intIn *gousb.InEndpoint
-	var intx *gousb.Interface
-	// Loop through the interfaces
-	for _, interfaceDesc := range cfg.Desc.Interfaces {
-		// TODO(mdr): I should probably check this interface or config to confirm
-		// it meets the USBTMC requirements.
-		intf, err := cfg.Interface(interfaceDesc.Number, 0)
+	usbDevices := make([]driver.USBDevice, 0, len(devs))
+	for i, dev := range devs {
+		d, err := newDevice(dev, iface)
 		if err != nil {
-			return nil, err
-		}
-		intx = intf
-		// Loop through all the endpoints on this interface
-		for _, ep := range intf.Setting.Endpoints {
-			isOut := ep.Direction == gousb.EndpointDirectionOut
-			isIn := ep.Direction == gousb.EndpointDirectionIn
-			isBulk := ep.TransferType == gousb.TransferTypeBulk
-			isInterrupt := ep.TransferType == gousb.TransferTypeInterrupt
-			if isOut && isBulk {
-				bulkOut, err = intf.OutEndpoint(ep.Number)
-				if err != nil {
-					return nil, err
-				}
+			for _, opened := range usbDevices {
+				_ = opened.Close()
 			}
-			if isIn && isBulk {
-				bulkIn, err = intf.InEndpoint(ep.Number)
-				if err != nil {
-					return nil, err
-				}
+			for _, rest := range devs[i+1:] {
+				_ = rest.Close()
 			}
-			if isIn && isInterrupt {
-				intIn, err = intf.InEndpoint(ep.Number)
-				if err != nil {
-					return nil, err
-				}
+			return nil, fmt.Errorf("bus %d address %d: %w", dev.Desc.Bus, dev.Desc.Address, err)
+		}
+		usbDevices = append(usbDevices, d)
+	}
+	return usbDevices, nil
+}
+
+// NewDeviceByPortPath creates a new USB device for the device on the given
+// bus whose chain of hub ports from the root hub is ports, claiming the
+// interface selected by iface as for NewDeviceByVIDPID.
+func (c *Context) NewDeviceByPortPath(bus int, ports []int, iface int) (driver.USBDevice, error) {
+	devs, err := c.ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		return desc.Bus == bus && slices.Equal(desc.Path, ports)
+	})
+	if len(devs) == 0 {
+		path := driver.DeviceInfo{Bus: bus, Path: ports}.PortPath()
+		if err != nil {
+			return nil, fmt.Errorf("no device found at USB port path %s: %w", path, err)
+		}
+		return nil, fmt.Errorf("no device found at USB port path %s", path)
+	}
+	// Only one device can be attached to a port.
+	for _, d := range devs[1:] {
+		_ = d.Close()
+	}
+	return newDevice(devs[0], iface)
+}
+
+// NewDeviceBySerial creates a new USB device for the USBTMC device with the
+// given serial number, claiming the interface selected by iface as for
+// NewDeviceByVIDPID. Only devices with a USBTMC interface are considered, and
+// unless they are negative, VID and PID must match as well.
+func (c *Context) NewDeviceBySerial(VID, PID int, sn string, iface int) (driver.USBDevice, error) {
+	devs, err := c.ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		return (VID < 0 || int(desc.Vendor) == VID) &&
+			(PID < 0 || int(desc.Product) == PID) &&
+			len(deviceInfos(desc)) > 0
+	})
+	var match *gousb.Device
+	for _, dev := range devs {
+		if match == nil {
+			if serial, serr := dev.SerialNumber(); serr == nil && serial == sn {
+				match = dev
+				continue
 			}
 		}
+		_ = dev.Close()
+	}
+	if match == nil {
+		if err != nil {
+			return nil, fmt.Errorf("no devices found with serial number %q: %w", sn, err)
+		}
+		return nil, fmt.Errorf("no devices found with serial number %q", sn)
 	}
+	return newDevice(match, iface)
+}
+
+// newDevice claims the USBTMC interface with the given number, or the lowest
+// numbered USBTMC interface if iface is driver.AnyInterface, of the opened
+// dev. The device is closed if the interface cannot be claimed.
+func newDevice(dev *gousb.Device, iface int) (*Device, error) {
+	d, err := claimInterface(dev, iface)
+	if err != nil {
+		_ = dev.Close()
+		return nil, err
+	}
+	return d, nil
+}
 
+// claimInterface implements newDevice, leaving dev open on failure.
+func claimInterface(dev *gousb.Device, iface int) (*Device, error) {
+	activeConfig, err := dev.ActiveConfigNum()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := dev.Config(activeConfig)
+	if err != nil {
+		return nil, err
+	}
+	number, err := selectInterface(cfg.Desc, iface)
+	if err != nil {
+		_ = cfg.Close()
+		return nil, err
+	}
+	intf, err := cfg.Interface(number, 0)
+	if err != nil {
+		_ = cfg.Close()
+		return nil, err
+	}
 	d := Device{
-		dev:                 dev,
-		intf:                intx,
-		cfg:                 cfg,
-		BulkInEndpoint:      bulkIn,
-		BulkOutEndpoint:     bulkOut,
-		InterruptInEndpoint: intIn,
+		dev:  dev,
+		intf: intf,
+		cfg:  cfg,
+		info: interfaceInfo(dev.Desc, intf.Setting),
+	}
+	readStrings(dev, &d.info)
+	// Loop through all the endpoints on the USBTMC interface.
+	for _, ep := range intf.Setting.Endpoints {
+		isOut := ep.Direction == gousb.EndpointDirectionOut
+		isIn := ep.Direction == gousb.EndpointDirectionIn
+		isBulk := ep.TransferType == gousb.TransferTypeBulk
+		isInterrupt := ep.TransferType == gousb.TransferTypeInterrupt
+		switch {
+		case isOut && isBulk:
+			d.BulkOutEndpoint, err = intf.OutEndpoint(ep.Number)
+		case isIn && isBulk:
+			d.BulkInEndpoint, err = intf.InEndpoint(ep.Number)
+		case isIn && isInterrupt:
+			d.InterruptInEndpoint, err = intf.InEndpoint(ep.Number)
+		}
+		if err != nil {
+			intf.Close()
+			_ = cfg.Close()
+			return nil, err
+		}
+	}
+	if d.BulkInEndpoint == nil || d.BulkOutEndpoint == nil {
+		intf.Close()
+		_ = cfg.Close()
+		return nil, fmt.Errorf("missing required bulk endpoints on interface %d", number)
 	}
 	return &d, nil
 }
 
+// selectInterface returns the number of the USBTMC interface with the given
+// number, or of the lowest numbered USBTMC interface if iface is
+// driver.AnyInterface, in the configuration described by cfg.
+func selectInterface(cfg gousb.ConfigDesc, iface int) (int, error) {
+	number := -1
+	for _, intf := range cfg.Interfaces {
+		if len(intf.AltSettings) == 0 || !isUSBTMC(intf.AltSettings[0]) {
+			continue
+		}
+		if intf.Number == iface {
+			return intf.Number, nil
+		}
+		if iface == driver.AnyInterface && (number < 0 || intf.Number < number) {
+			number = intf.Number
+		}
+	}
+	if number >= 0 {
+		return number, nil
+	}
+	if iface == driver.AnyInterface {
+		return 0, fmt.Errorf("no USBTMC interface found in configuration %d", cfg.Number)
+	}
+	return 0, fmt.Errorf("interface %d is not a USBTMC interface", iface)
+}
+
 func exitBootMode(dev *gousb.Device, bootPID gousb.ID) error {
 	thirdIndex := uint16(0x0487)
 	if bootPID == 0x2818 || bootPID == 0x3E18 {
@@ -233,3 +359,73 @@ func exitBootMode(dev *gousb.Device, bootPID gousb.ID) error {
 	time.Sleep(rebootDelay)
 	return nil
 }
+
+// ListDevices returns a DeviceInfo for every USBTMC interface of the attached
+// devices. The string descriptors are left empty for devices that cannot be
+// opened, such as when the user lacks permission to access them.
+func (c *Context) ListDevices() ([]driver.DeviceInfo, error) {
+	var infos []driver.DeviceInfo
+	found := map[*gousb.DeviceDesc][]int{}
+	devs, err := c.ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		for _, info := range deviceInfos(desc) {
+			found[desc] = append(found[desc], len(infos))
+			infos = append(infos, info)
+		}
+		return len(found[desc]) > 0
+	})
+	if err != nil && len(infos) == 0 {
+		return nil, err
+	}
+	// Devices that could not be opened are still listed, without the
+	// string descriptors.
+	for _, dev := range devs {
+		for _, i := range found[dev.Desc] {
+			readStrings(dev, &infos[i])
+		}
+		_ = dev.Close()
+	}
+	return infos, nil
+}
+
+// deviceInfos returns a DeviceInfo for each USBTMC interface described by
+// desc, using the first alternate setting of each interface.
+func deviceInfos(desc *gousb.DeviceDesc) []driver.DeviceInfo {
+	var infos []driver.DeviceInfo
+	for _, cfg := range desc.Configs {
+		for _, intf := range cfg.Interfaces {
+			if len(intf.AltSettings) == 0 || !isUSBTMC(intf.AltSettings[0]) {
+				continue
+			}
+			infos = append(infos, interfaceInfo(desc, intf.AltSettings[0]))
+		}
+	}
+	return infos
+}
+
+// interfaceInfo returns the DeviceInfo for the interface setting s of the
+// device described by desc, without the string descriptors.
+func interfaceInfo(desc *gousb.DeviceDesc, s gousb.InterfaceSetting) driver.DeviceInfo {
+	return driver.DeviceInfo{
+		VendorID:          int(desc.Vendor),
+		ProductID:         int(desc.Product),
+		Bus:               desc.Bus,
+		Address:           desc.Address,
+		Path:              desc.Path,
+		InterfaceNumber:   s.Number,
+		InterfaceProtocol: uint8(s.Protocol),
+	}
+}
+
+// readStrings sets the string descriptors of info from the opened dev. Strings
+// that cannot be read are left empty.
+func readStrings(dev *gousb.Device, info *driver.DeviceInfo) {
+	info.SerialNumber, _ = dev.SerialNumber()
+	info.Manufacturer, _ = dev.Manufacturer()
+	info.Product, _ = dev.Product()
+}
+
+// isUSBTMC reports whether the interface setting has the USBTMC class and
+// subclass codes given in Table 43 of the USBTMC Specification 1.0.
+func isUSBTMC(s gousb.InterfaceSetting) bool {
+	return s.Class == gousb.ClassApplication && s.SubClass == usbtmcSubClass
+}
@@ -9,8 +9,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/gousb"
+	"github.com/gotmc/usbtmc/driver"
+)
+
+// defaultControlTimeout is the control transfer timeout used when the context
+// has no deadline.
+const defaultControlTimeout = 2 * time.Second
+
+// Standard USB request used to clear an endpoint halt per Table 9-4 and
+// Table 9-6 of the USB 2.0 Specification.
+const (
+	requestTypeStandardEndpointOut uint8  = 0x02
+	requestClearFeature            uint8  = 0x01
+	featureEndpointHalt            uint16 = 0x00
 )
 
 // Device represents a USB device not a USBMTC device.
@@ -21,6 +35,7 @@ type Device struct {
 	BulkInEndpoint      *gousb.InEndpoint
 	BulkOutEndpoint     *gousb.OutEndpoint
 	InterruptInEndpoint *gousb.InEndpoint
+	info                driver.DeviceInfo
 }
 
 // Close closes the Device.
@@ -36,7 +51,8 @@ func (d *Device) String() string {
 
 // Write writes to the USB device's bulk out endpoint.
 func (d *Device) Write(p []byte) (n int, err error) {
-	return d.BulkOutEndpoint.Write(p)
+	n, err = d.BulkOutEndpoint.Write(p)
+	return n, wrapError(err)
 }
 
 // WriteString writes the given string to the Device and returns the number
@@ -47,17 +63,110 @@ func (d *Device) WriteString(s string) (n int, err error) {
 
 // Read reads from the USB device's bulk in endpoint.
 func (d *Device) Read(p []byte) (n int, err error) {
-	return d.BulkInEndpoint.Read(p)
+	n, err = d.BulkInEndpoint.Read(p)
+	return n, wrapError(err)
 }
 
 // ReadContext reads from the USB device's bulk in endpoint in a context aware
 // manner.
 func (d *Device) ReadContext(ctx context.Context, p []byte) (n int, err error) {
-	return d.BulkInEndpoint.ReadContext(ctx, p)
+	n, err = d.BulkInEndpoint.ReadContext(ctx, p)
+	return n, wrapError(err)
 }
 
 // WriteContext writes to the USB device's bulk out endpoint in a context aware
 // manner.
 func (d *Device) WriteContext(ctx context.Context, p []byte) (n int, err error) {
-	return d.BulkOutEndpoint.WriteContext(ctx, p)
+	n, err = d.BulkOutEndpoint.WriteContext(ctx, p)
+	return n, wrapError(err)
+}
+
+// ControlContext performs a control transfer on the USB device's default
+// control endpoint. If the context has a deadline, it is used as the control
+// transfer timeout; otherwise the default timeout is used.
+func (d *Device) ControlContext(
+	ctx context.Context,
+	bmRequestType, bRequest uint8,
+	wValue, wIndex uint16,
+	data []byte,
+) (n int, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	// gousb control transfers are synchronous and are bounded by the
+	// device's ControlTimeout rather than a context.
+	d.dev.ControlTimeout = defaultControlTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		d.dev.ControlTimeout = max(time.Until(deadline), time.Millisecond)
+	}
+	return d.dev.Control(bmRequestType, bRequest, wValue, wIndex, data)
+}
+
+// ClearHalt clears the halt condition of the endpoint with the given address.
+// gousb has no wrapper for libusb_clear_halt, so the CLEAR_FEATURE request is
+// sent as a control transfer, which leaves the host side data toggle of the
+// endpoint as it was.
+func (d *Device) ClearHalt(ctx context.Context, endpoint uint8) error {
+	_, err := d.ControlContext(ctx, requestTypeStandardEndpointOut,
+		requestClearFeature, featureEndpointHalt, uint16(endpoint), nil)
+	return err
+}
+
+// Info returns the DeviceInfo describing the device and its claimed USBTMC
+// interface.
+func (d *Device) Info() driver.DeviceInfo {
+	return d.info
+}
+
+// InterfaceNumber returns the number of the claimed USBTMC interface.
+func (d *Device) InterfaceNumber() int {
+	return d.intf.Setting.Number
+}
+
+// BulkInEndpointAddress returns the address of the bulk in endpoint.
+func (d *Device) BulkInEndpointAddress() uint8 {
+	return uint8(d.BulkInEndpoint.Desc.Address)
+}
+
+// BulkOutEndpointAddress returns the address of the bulk out endpoint.
+func (d *Device) BulkOutEndpointAddress() uint8 {
+	return uint8(d.BulkOutEndpoint.Desc.Address)
+}
+
+// BulkInMaxPacketSize returns the maximum packet size of the bulk in endpoint.
+func (d *Device) BulkInMaxPacketSize() int {
+	return d.BulkInEndpoint.Desc.MaxPacketSize
+}
+
+// BulkOutMaxPacketSize returns the maximum packet size of the bulk out
+// endpoint.
+func (d *Device) BulkOutMaxPacketSize() int {
+	return d.BulkOutEndpoint.Desc.MaxPacketSize
+}
+
+// HasInterruptIn reports whether the USB device has an interrupt in endpoint.
+func (d *Device) HasInterruptIn() bool {
+	return d.InterruptInEndpoint != nil
+}
+
+// ReadInterruptContext reads from the USB device's interrupt in endpoint in a
+// context aware manner.
+func (d *Device) ReadInterruptContext(ctx context.Context, p []byte) (n int, err error) {
+	if d.InterruptInEndpoint == nil {
+		return 0, errors.New("usb device has no interrupt in endpoint")
+	}
+	n, err = d.InterruptInEndpoint.ReadContext(ctx, p)
+	return n, wrapError(err)
+}
+
+// wrapError wraps errors reporting a halted endpoint with driver.ErrStall and
+// errors reporting a timed out transfer with driver.ErrTimeout.
+func wrapError(err error) error {
+	switch {
+	case errors.Is(err, gousb.ErrorPipe) || errors.Is(err, gousb.TransferStall):
+		return fmt.Errorf("%w: %w", driver.ErrStall, err)
+	case errors.Is(err, gousb.ErrorTimeout) || errors.Is(err, gousb.TransferTimedOut):
+		return fmt.Errorf("%w: %w", driver.ErrTimeout, err)
+	}
+	return err
 }
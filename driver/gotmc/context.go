@@ -6,14 +6,27 @@
 package gotmc
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
 	libusb "github.com/gotmc/libusb/v2"
 	"github.com/gotmc/usbtmc"
 	"github.com/gotmc/usbtmc/driver"
 )
 
+// The USB class codes of a USBTMC interface per Table 43 of the USBTMC
+// Specification 1.0.
+const (
+	interfaceClassApplication uint8 = 0xfe
+	interfaceSubClassUSBTMC   uint8 = 0x03
+)
+
 // Driver implements the visa.Driver interface required by usbtmc using the
 // github.com/gotmc/libusb libusb driver.
 type Driver struct {
@@ -48,13 +61,190 @@ func (c *Context) Close() error {
 }
 
 // NewDeviceByVIDPID creates new USB device based on the given the
-// vendor ID and product ID. If multiple USB devices matching the VID and PID
-// are found, only the first is returned.
-func (c *Context) NewDeviceByVIDPID(VID, PID int) (driver.USBDevice, error) {
+// vendor ID and product ID, claiming the USBTMC interface with the number
+// iface, or the lowest numbered USBTMC interface if iface is
+// driver.AnyInterface. If multiple USB devices matching the VID and PID are
+// found, only the first is returned.
+func (c *Context) NewDeviceByVIDPID(VID, PID, iface int) (driver.USBDevice, error) {
 	dev, dh, err := c.ctx.OpenDeviceWithVendorProduct(uint16(VID), uint16(PID)) //nolint:gosec
 	if err != nil {
 		return nil, err
 	}
+	return newDevice(dev, dh, iface)
+}
+
+// NewDevicesByVIDPID creates a new USB device for every device matching the
+// given vendor ID and product ID, claiming the interface selected by iface as
+// for NewDeviceByVIDPID. If any device cannot be opened, the devices already
+// opened are closed and an error is returned.
+func (c *Context) NewDevicesByVIDPID(VID, PID, iface int) ([]driver.USBDevice, error) {
+	devs, err := c.ctx.DeviceList()
+	if err != nil {
+		return nil, err
+	}
+	var usbDevices []driver.USBDevice
+	// closeAll closes the devices already opened along with devs[i:], which
+	// starts with the device that failed to open.
+	closeAll := func(i int) {
+		for _, d := range usbDevices {
+			_ = d.Close()
+		}
+		for _, dev := range devs[i:] {
+			dev.Close()
+		}
+	}
+	for i, dev := range devs {
+		desc, err := dev.DeviceDescriptor()
+		if err != nil || int(desc.VendorID) != VID || int(desc.ProductID) != PID {
+			dev.Close()
+			continue
+		}
+		dh, err := dev.Open()
+		if err != nil {
+			closeAll(i)
+			return nil, fmt.Errorf("error opening USB device: %w", err)
+		}
+		d, err := newDevice(dev, dh, iface)
+		if err != nil {
+			closeAll(i)
+			return nil, err
+		}
+		usbDevices = append(usbDevices, d)
+	}
+	if len(usbDevices) == 0 {
+		return nil, fmt.Errorf("no devices found matching VID %v and PID %v", VID, PID)
+	}
+	return usbDevices, nil
+}
+
+// NewDeviceByPortPath creates a new USB device for the device on the given
+// bus whose chain of hub ports from the root hub is ports, claiming the
+// interface selected by iface as for NewDeviceByVIDPID. The libusb package
+// only reports the port on the device's parent hub, so the full chain is read
+// from sysfs where available.
+func (c *Context) NewDeviceByPortPath(bus int, ports []int, iface int) (driver.USBDevice, error) {
+	devs, err := c.ctx.DeviceList()
+	if err != nil {
+		return nil, err
+	}
+	var match *libusb.Device
+	for _, dev := range devs {
+		if match == nil {
+			b, berr := dev.BusNumber()
+			address, aerr := dev.DeviceAddress()
+			if berr == nil && aerr == nil && b == bus &&
+				slices.Equal(portPath(dev, b, address), ports) {
+				match = dev
+				continue
+			}
+		}
+		dev.Close()
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no device found at USB port path %s",
+			driver.DeviceInfo{Bus: bus, Path: ports}.PortPath())
+	}
+	dh, err := match.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening USB device: %w", err)
+	}
+	return newDevice(match, dh, iface)
+}
+
+// NewDeviceBySerial creates a new USB device for the USBTMC device with the
+// given serial number, claiming the interface selected by iface as for
+// NewDeviceByVIDPID. Only devices with a USBTMC interface are considered, and
+// unless they are negative, VID and PID must match as well.
+func (c *Context) NewDeviceBySerial(VID, PID int, sn string, iface int) (driver.USBDevice, error) {
+	devs, err := c.ctx.DeviceList()
+	if err != nil {
+		return nil, err
+	}
+	var match *libusb.Device
+	var dh *libusb.DeviceHandle
+	for _, dev := range devs {
+		if match == nil {
+			if dh = openWithSerial(dev, VID, PID, sn, iface); dh != nil {
+				match = dev
+				continue
+			}
+		}
+		dev.Close()
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no devices found with serial number %q", sn)
+	}
+	return newDevice(match, dh, iface)
+}
+
+// openWithSerial opens dev if it has the USBTMC interface selected by iface,
+// the given serial number and, unless they are negative, the vendor ID VID
+// and product ID PID, returning nil otherwise.
+func openWithSerial(dev *libusb.Device, VID, PID int, sn string, iface int) *libusb.DeviceHandle {
+	desc, err := dev.DeviceDescriptor()
+	if err != nil || desc.SerialNumberIndex == 0 ||
+		(VID >= 0 && int(desc.VendorID) != VID) ||
+		(PID >= 0 && int(desc.ProductID) != PID) {
+		return nil
+	}
+	cfg, err := dev.ActiveConfigDescriptor()
+	if err != nil {
+		return nil
+	}
+	if _, err := selectInterface(cfg, iface); err != nil {
+		return nil
+	}
+	dh, err := dev.Open()
+	if err != nil {
+		return nil
+	}
+	if stringDescriptor(dh, desc.SerialNumberIndex) != sn {
+		_ = dh.Close()
+		return nil
+	}
+	return dh
+}
+
+// selectInterface returns the USBTMC interface with the given number, or the
+// lowest numbered USBTMC interface if iface is driver.AnyInterface, in the
+// configuration described by cfg.
+func selectInterface(
+	cfg *libusb.ConfigDescriptor,
+	iface int,
+) (*libusb.InterfaceDescriptor, error) {
+	var found *libusb.InterfaceDescriptor
+	for _, si := range cfg.SupportedInterfaces {
+		if len(si.InterfaceDescriptors) == 0 {
+			continue
+		}
+		intf := si.InterfaceDescriptors[0]
+		if !isUSBTMC(intf) {
+			continue
+		}
+		if intf.InterfaceNumber == iface {
+			return intf, nil
+		}
+		if iface == driver.AnyInterface &&
+			(found == nil || intf.InterfaceNumber < found.InterfaceNumber) {
+			found = intf
+		}
+	}
+	if found != nil {
+		return found, nil
+	}
+	if iface == driver.AnyInterface {
+		return nil, errors.New("no USBTMC interface found in the active configuration")
+	}
+	return nil, fmt.Errorf("interface %d is not a USBTMC interface", iface)
+}
+
+// newDevice claims the USBTMC interface selected by iface of the opened dev.
+// The device handle is closed if the interface cannot be claimed.
+func newDevice(
+	dev *libusb.Device,
+	dh *libusb.DeviceHandle,
+	iface int,
+) (*Device, error) {
 	usbDeviceDescriptor, err := dev.DeviceDescriptor()
 	if err != nil {
 		_ = dh.Close()
@@ -66,16 +256,20 @@ func (c *Context) NewDeviceByVIDPID(VID, PID int) (driver.USBDevice, error) {
 		return nil, fmt.Errorf("failed getting active config: %w", err)
 	}
 	log.Printf("Grabbed active config: %v", configDescriptor)
-	firstDescriptor := configDescriptor.SupportedInterfaces[0].InterfaceDescriptors[0]
-	err = dh.ClaimInterface(0)
+	intf, err := selectInterface(configDescriptor, iface)
+	if err != nil {
+		_ = dh.Close()
+		return nil, err
+	}
+	err = dh.ClaimInterface(intf.InterfaceNumber)
 	if err != nil {
 		_ = dh.Close()
 		return nil, fmt.Errorf("error claiming USB interface: %w", err)
 	}
-	log.Println("Claimed interface 0")
-	log.Printf("Found %d endpoint descriptors", len(firstDescriptor.EndpointDescriptors))
+	log.Printf("Claimed interface %d", intf.InterfaceNumber)
+	log.Printf("Found %d endpoint descriptors", len(intf.EndpointDescriptors))
 	var bulkIn, bulkOut, interruptIn *libusb.EndpointDescriptor
-	for _, ep := range firstDescriptor.EndpointDescriptors {
+	for _, ep := range intf.EndpointDescriptors {
 		switch {
 		case ep.Direction() == 0 && ep.TransferType() == libusb.BulkTransfer:
 			bulkOut = ep
@@ -87,7 +281,8 @@ func (c *Context) NewDeviceByVIDPID(VID, PID int) (driver.USBDevice, error) {
 	}
 	if bulkIn == nil || bulkOut == nil {
 		_ = dh.Close()
-		return nil, fmt.Errorf("missing required bulk endpoints on device")
+		return nil, fmt.Errorf("missing required bulk endpoints on interface %d",
+			intf.InterfaceNumber)
 	}
 
 	d := Device{
@@ -96,9 +291,157 @@ func (c *Context) NewDeviceByVIDPID(VID, PID int) (driver.USBDevice, error) {
 		DeviceDescriptor:  usbDeviceDescriptor,
 		DeviceHandle:      dh,
 		ConfigDescriptor:  configDescriptor,
+		Interface:         intf,
 		BulkInEndpoint:    bulkIn,
 		BulkOutEndpoint:   bulkOut,
 		InterruptEndpoint: interruptIn,
+		info:              interfaceInfo(dev, usbDeviceDescriptor, intf),
 	}
+	readStrings(dh, usbDeviceDescriptor, &d.info)
 	return &d, nil
 }
+
+// ListDevices returns a DeviceInfo for every USBTMC interface of the attached
+// devices. The string descriptors are left empty for devices that cannot be
+// opened, such as when the user lacks permission to access them.
+func (c *Context) ListDevices() ([]driver.DeviceInfo, error) {
+	devs, err := c.ctx.DeviceList()
+	if err != nil {
+		return nil, err
+	}
+	var infos []driver.DeviceInfo
+	for _, dev := range devs {
+		infos = append(infos, deviceInfos(dev)...)
+		dev.Close()
+	}
+	return infos, nil
+}
+
+// deviceInfos returns a DeviceInfo for each USBTMC interface in the active
+// configuration of dev. Devices whose descriptors cannot be read, such as
+// unconfigured devices, have no USBTMC interfaces to report.
+func deviceInfos(dev *libusb.Device) []driver.DeviceInfo {
+	desc, err := dev.DeviceDescriptor()
+	if err != nil {
+		return nil
+	}
+	cfg, err := dev.ActiveConfigDescriptor()
+	if err != nil {
+		return nil
+	}
+	var infos []driver.DeviceInfo
+	for _, si := range cfg.SupportedInterfaces {
+		if len(si.InterfaceDescriptors) == 0 {
+			continue
+		}
+		intf := si.InterfaceDescriptors[0]
+		if !isUSBTMC(intf) {
+			continue
+		}
+		infos = append(infos, interfaceInfo(dev, desc, intf))
+	}
+	if len(infos) == 0 {
+		return nil
+	}
+	if dh, err := dev.Open(); err == nil {
+		for i := range infos {
+			readStrings(dh, desc, &infos[i])
+		}
+		_ = dh.Close()
+	}
+	return infos
+}
+
+// interfaceInfo returns the DeviceInfo for the interface intf of dev, without
+// the string descriptors.
+func interfaceInfo(
+	dev *libusb.Device,
+	desc *libusb.Descriptor,
+	intf *libusb.InterfaceDescriptor,
+) driver.DeviceInfo {
+	bus, _ := dev.BusNumber()
+	address, _ := dev.DeviceAddress()
+	path := portPath(dev, bus, address)
+	return driver.DeviceInfo{
+		VendorID:          int(desc.VendorID),
+		ProductID:         int(desc.ProductID),
+		Bus:               bus,
+		Address:           address,
+		Path:              path,
+		InterfaceNumber:   intf.InterfaceNumber,
+		InterfaceProtocol: intf.InterfaceProtocol,
+	}
+}
+
+// readStrings sets the string descriptors of info using the device handle dh.
+// Strings that cannot be read are left empty.
+func readStrings(dh *libusb.DeviceHandle, desc *libusb.Descriptor, info *driver.DeviceInfo) {
+	info.SerialNumber = stringDescriptor(dh, desc.SerialNumberIndex)
+	info.Manufacturer = stringDescriptor(dh, desc.ManufacturerIndex)
+	info.Product = stringDescriptor(dh, desc.ProductIndex)
+}
+
+// isUSBTMC reports whether the interface has the USBTMC class and subclass
+// codes given in Table 43 of the USBTMC Specification 1.0.
+func isUSBTMC(intf *libusb.InterfaceDescriptor) bool {
+	return intf.InterfaceClass == interfaceClassApplication &&
+		intf.InterfaceSubClass == interfaceSubClassUSBTMC
+}
+
+// stringDescriptor returns the string descriptor with the given index, or an
+// empty string if the device has no such string or it cannot be read.
+func stringDescriptor(dh *libusb.DeviceHandle, index uint8) string {
+	if index == 0 {
+		return ""
+	}
+	s, err := dh.StringDescriptorASCII(index)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// sysfsUSBDevices is the Linux sysfs directory listing USB devices by their
+// port path, such as "1-2.3".
+const sysfsUSBDevices = "/sys/bus/usb/devices"
+
+// portPath returns the chain of hub ports from the root hub to dev, which has
+// the given bus number and device address. The full chain is read from sysfs
+// where available; otherwise only the port on the parent hub is returned.
+func portPath(dev *libusb.Device, bus, address int) []int {
+	if ports := sysfsPortPath(bus, address); ports != nil {
+		return ports
+	}
+	if port, err := dev.PortNumber(); err == nil && port != 0 {
+		return []int{port}
+	}
+	return nil
+}
+
+// sysfsPortPath looks up the port path of the device with the given bus number
+// and device address in sysfs, returning nil if it cannot be found.
+func sysfsPortPath(bus, address int) []int {
+	entries, err := os.ReadDir(sysfsUSBDevices)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		// Interfaces are listed as "1-2.3:1.0" and root hubs as "usb1".
+		name := entry.Name()
+		if strings.Contains(name, ":") {
+			continue
+		}
+		b, ports, err := driver.ParsePortPath(name)
+		if err != nil || b != bus {
+			continue
+		}
+		devnum, err := os.ReadFile(filepath.Join(sysfsUSBDevices, name, "devnum"))
+		if err != nil {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(string(devnum))); err == nil && n == address {
+			return ports
+		}
+	}
+	return nil
+}
@@ -7,9 +7,28 @@ package gotmc
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	libusb "github.com/gotmc/libusb/v2"
+	"github.com/gotmc/usbtmc/driver"
+)
+
+// errorTimeout is LIBUSB_ERROR_TIMEOUT and errorPipe is LIBUSB_ERROR_PIPE,
+// which libusb returns when a transfer times out and when an endpoint is
+// halted. The libusb package does not export its error codes.
+const (
+	errorTimeout = libusb.ErrorCode(-7)
+	errorPipe    = libusb.ErrorCode(-9)
+)
+
+// Standard USB request used to clear an endpoint halt per Table 9-4 and
+// Table 9-6 of the USB 2.0 Specification.
+const (
+	requestTypeStandardEndpointOut uint8  = 0x02
+	requestClearFeature            uint8  = 0x01
+	featureEndpointHalt            uint16 = 0x00
 )
 
 // Device models the libusb device that will form the basis of the USBTMC
@@ -20,9 +39,11 @@ type Device struct {
 	DeviceDescriptor  *libusb.Descriptor
 	DeviceHandle      *libusb.DeviceHandle
 	ConfigDescriptor  *libusb.ConfigDescriptor
+	Interface         *libusb.InterfaceDescriptor
 	BulkInEndpoint    *libusb.EndpointDescriptor
 	BulkOutEndpoint   *libusb.EndpointDescriptor
 	InterruptEndpoint *libusb.EndpointDescriptor
+	info              driver.DeviceInfo
 }
 
 // Close closes the Device.
@@ -37,12 +58,13 @@ func (d *Device) String() string {
 
 // Write writes to the USB device's bulk out endpoint.
 func (d *Device) Write(p []byte) (n int, err error) {
-	return d.DeviceHandle.BulkTransfer(
+	n, err = d.DeviceHandle.BulkTransfer(
 		d.BulkOutEndpoint.EndpointAddress,
 		p,
 		len(p),
 		d.Timeout,
 	)
+	return n, wrapError(err)
 }
 
 // WriteString writes the given string to the Device and returns the number
@@ -53,12 +75,13 @@ func (d *Device) WriteString(s string) (n int, err error) {
 
 // Read reads from the USB device's bulk in endpoint.
 func (d *Device) Read(p []byte) (n int, err error) {
-	return d.DeviceHandle.BulkTransfer(
+	n, err = d.DeviceHandle.BulkTransfer(
 		d.BulkInEndpoint.EndpointAddress,
 		p,
 		len(p),
 		d.Timeout,
 	)
+	return n, wrapError(err)
 }
 
 // ReadContext reads from the USB device's bulk in endpoint in a context aware
@@ -68,12 +91,13 @@ func (d *Device) ReadContext(ctx context.Context, p []byte) (n int, err error) {
 	if err := ctx.Err(); err != nil {
 		return 0, err
 	}
-	return d.DeviceHandle.BulkTransfer(
+	n, err = d.DeviceHandle.BulkTransfer(
 		d.BulkInEndpoint.EndpointAddress,
 		p,
 		len(p),
 		d.contextTimeout(ctx),
 	)
+	return n, wrapError(err)
 }
 
 // WriteContext writes to the USB device's bulk out endpoint in a context aware
@@ -83,12 +107,111 @@ func (d *Device) WriteContext(ctx context.Context, p []byte) (n int, err error)
 	if err := ctx.Err(); err != nil {
 		return 0, err
 	}
-	return d.DeviceHandle.BulkTransfer(
+	n, err = d.DeviceHandle.BulkTransfer(
 		d.BulkOutEndpoint.EndpointAddress,
 		p,
 		len(p),
 		d.contextTimeout(ctx),
 	)
+	return n, wrapError(err)
+}
+
+// ControlContext performs a control transfer on the USB device's default
+// control endpoint in a context aware manner. If the context has a deadline,
+// it is converted to a libusb timeout in milliseconds; otherwise the device's
+// default timeout is used.
+func (d *Device) ControlContext(
+	ctx context.Context,
+	bmRequestType, bRequest uint8,
+	wValue, wIndex uint16,
+	data []byte,
+) (n int, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return d.DeviceHandle.ControlTransfer(
+		bmRequestType,
+		bRequest,
+		wValue,
+		wIndex,
+		data,
+		len(data),
+		d.contextTimeout(ctx),
+	)
+}
+
+// ClearHalt clears the halt condition of the endpoint with the given address.
+// The libusb package does not wrap libusb_clear_halt, so the CLEAR_FEATURE
+// request is sent as a control transfer, which leaves the host side data
+// toggle of the endpoint as it was.
+func (d *Device) ClearHalt(ctx context.Context, endpoint uint8) error {
+	_, err := d.ControlContext(ctx, requestTypeStandardEndpointOut,
+		requestClearFeature, featureEndpointHalt, uint16(endpoint), nil)
+	return err
+}
+
+// Info returns the DeviceInfo describing the device and its claimed USBTMC
+// interface.
+func (d *Device) Info() driver.DeviceInfo {
+	return d.info
+}
+
+// InterfaceNumber returns the number of the claimed USBTMC interface.
+func (d *Device) InterfaceNumber() int {
+	return d.Interface.InterfaceNumber
+}
+
+// BulkInEndpointAddress returns the address of the bulk in endpoint.
+func (d *Device) BulkInEndpointAddress() uint8 {
+	return uint8(d.BulkInEndpoint.EndpointAddress)
+}
+
+// BulkOutEndpointAddress returns the address of the bulk out endpoint.
+func (d *Device) BulkOutEndpointAddress() uint8 {
+	return uint8(d.BulkOutEndpoint.EndpointAddress)
+}
+
+// BulkInMaxPacketSize returns the maximum packet size of the bulk in endpoint.
+func (d *Device) BulkInMaxPacketSize() int {
+	return maxPacketSize(d.BulkInEndpoint)
+}
+
+// BulkOutMaxPacketSize returns the maximum packet size of the bulk out
+// endpoint.
+func (d *Device) BulkOutMaxPacketSize() int {
+	return maxPacketSize(d.BulkOutEndpoint)
+}
+
+// maxPacketSize returns the packet size held in bits 10..0 of the endpoint's
+// wMaxPacketSize. Bits 12..11 only apply to high-speed isochronous and
+// interrupt endpoints.
+func maxPacketSize(ep *libusb.EndpointDescriptor) int {
+	return int(ep.MaxPacketSize & 0x7ff)
+}
+
+// HasInterruptIn reports whether the USB device has an interrupt in endpoint.
+func (d *Device) HasInterruptIn() bool {
+	return d.InterruptEndpoint != nil
+}
+
+// ReadInterruptContext reads from the USB device's interrupt in endpoint in a
+// context aware manner. If the context has a deadline, it is converted to a
+// libusb timeout in milliseconds; otherwise the device's default timeout is
+// used.
+func (d *Device) ReadInterruptContext(ctx context.Context, p []byte) (n int, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if d.InterruptEndpoint == nil {
+		return 0, errors.New("usb device has no interrupt in endpoint")
+	}
+	n, err = d.DeviceHandle.InterruptTransfer(
+		d.InterruptEndpoint.EndpointAddress,
+		p,
+		len(p),
+		d.contextTimeout(ctx),
+	)
+	return n, wrapError(err)
 }
 
 // contextTimeout returns a libusb timeout in milliseconds derived from the
@@ -96,7 +219,9 @@ func (d *Device) WriteContext(ctx context.Context, p []byte) (n int, err error)
 // returned.
 func (d *Device) contextTimeout(ctx context.Context) int {
 	if deadline, ok := ctx.Deadline(); ok {
-		ms := time.Until(deadline).Milliseconds()
+		// Round up so that the transfer does not time out before the
+		// deadline has passed.
+		ms := (time.Until(deadline) + time.Millisecond - 1).Milliseconds()
 		if ms <= 0 {
 			return 1 // minimum timeout to avoid blocking indefinitely
 		}
@@ -104,3 +229,19 @@ func (d *Device) contextTimeout(ctx context.Context) int {
 	}
 	return d.Timeout
 }
+
+// wrapError wraps errors reporting a halted endpoint with driver.ErrStall and
+// errors reporting a timed out transfer with driver.ErrTimeout.
+func wrapError(err error) error {
+	var code libusb.ErrorCode
+	if !errors.As(err, &code) {
+		return err
+	}
+	switch code {
+	case errorPipe:
+		return fmt.Errorf("%w: %w", driver.ErrStall, err)
+	case errorTimeout:
+		return fmt.Errorf("%w: %w", driver.ErrTimeout, err)
+	}
+	return err
+}
@@ -17,6 +17,16 @@ func nextbTag(bTag byte) byte {
 	return (bTag % 255) + 1
 }
 
+// nextStatusTag returns the next bTag for a READ_STATUS_BYTE request given the
+// current one. Per USB488 Table 11, "the Host must set bTag such that
+// 2<=bTag<=127."
+func nextStatusTag(bTag byte) byte {
+	if bTag < 2 || bTag >= 127 {
+		return 2
+	}
+	return bTag + 1
+}
+
 // intertbTag returns the one's complement (inverse) of the given bTag.
 func invertbTag(bTag byte) byte {
 	return bTag ^ 0xff
@@ -120,3 +130,64 @@ func encodeMsgInBulkOutHeader(
 		reservedField,
 	}
 }
+
+// Create the vendorSpecificOut Bulk-OUT Header as shown in USBTMC Table 5.
+// Vendor specific transfers have no end-of-message, so eom is ignored. It is
+// accepted so that this function can be used as a headerEncoder.
+func encodeVendorSpecificOutHeader(bTag byte, transferSize uint32, _ bool) [12]byte {
+	return encodeVendorSpecificHeader(bTag, vendorSpecificOut, transferSize)
+}
+
+// Create the requestVendorSpecificIn Bulk-OUT Header as shown in USBTMC Table
+// 6.
+func encodeRequestVendorSpecificInHeader(bTag byte, transferSize uint32) [12]byte {
+	return encodeVendorSpecificHeader(bTag, requestVendorSpecificIn, transferSize)
+}
+
+func encodeVendorSpecificHeader(bTag byte, id msgID, transferSize uint32) [12]byte {
+	// Offset 0-3: See Table 1.
+	prefix := encodeBulkHeaderPrefix(bTag, id)
+	// Offset 4-7: TransferSize
+	// For VENDOR_SPECIFIC_OUT this is the total number of message data bytes
+	// in this transfer, and for REQUEST_VENDOR_SPECIFIC_IN the maximum number
+	// of message data bytes the device may send in response. In both cases it
+	// excludes the header and alignment bytes and must be > 0x00000000.
+	packedTransferSize := make([]byte, 4)
+	binary.LittleEndian.PutUint32(packedTransferSize, transferSize)
+	// Offset 8-11: reservedField. Must be 0x00000000.
+	return [12]byte{
+		prefix[0],
+		prefix[1],
+		prefix[2],
+		prefix[3],
+		packedTransferSize[0],
+		packedTransferSize[1],
+		packedTransferSize[2],
+		packedTransferSize[3],
+		reservedField,
+		reservedField,
+		reservedField,
+		reservedField,
+	}
+}
+
+// Create the USB488 TRIGGER Bulk-OUT Header as shown in USB488 Table 2.
+func encodeTriggerHeader(bTag byte) [12]byte {
+	// Offset 0-3: See USBTMC Table 1.
+	prefix := encodeBulkHeaderPrefix(bTag, trigger)
+	// Offset 4-11: reservedField. Must be 0x00.
+	return [12]byte{
+		prefix[0],
+		prefix[1],
+		prefix[2],
+		prefix[3],
+		reservedField,
+		reservedField,
+		reservedField,
+		reservedField,
+		reservedField,
+		reservedField,
+		reservedField,
+		reservedField,
+	}
+}
@@ -34,6 +34,30 @@ func TestNextBtag(t *testing.T) {
 	}
 }
 
+func TestNextStatusTag(t *testing.T) {
+	testCases := []struct {
+		bTag          byte
+		nextStatusTag byte
+	}{
+		{0, 2},
+		{1, 2},
+		{2, 3},
+		{126, 127},
+		{127, 2},
+		{255, 2},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("bTag_%d", tc.bTag), func(t *testing.T) {
+			got := nextStatusTag(tc.bTag)
+			if got != tc.nextStatusTag {
+				t.Errorf(
+					"nextStatusTag == %d, want %d for given bTag %d",
+					got, tc.nextStatusTag, tc.bTag)
+			}
+		})
+	}
+}
+
 func TestInvertingBtag(t *testing.T) {
 	testCases := []struct {
 		bTag        byte
@@ -161,3 +185,56 @@ func TestEncodeMsgInBulkOutHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeTriggerHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		bTag    byte
+		desired [12]byte
+	}{
+		{
+			"bTag1",
+			1,
+			[12]byte{0x80, 0x01, 0xfe, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			"bTag129",
+			129,
+			[12]byte{0x80, 0x81, 0x7e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := encodeTriggerHeader(tt.bTag)
+			if got != tt.desired {
+				t.Errorf("header == %x, want %x", got, tt.desired)
+			}
+		})
+	}
+}
+
+func TestEncodeVendorSpecificHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		got     [12]byte
+		desired [12]byte
+	}{
+		{
+			"out_size9_bTag1",
+			encodeVendorSpecificOutHeader(1, 9, true),
+			[12]byte{0x7e, 0x01, 0xfe, 0x00, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			"request_in_size512_bTag2",
+			encodeRequestVendorSpecificInHeader(2, 512),
+			[12]byte{0x7f, 0x02, 0xfd, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.desired {
+				t.Errorf("header == %x, want %x", tt.got, tt.desired)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gotmc/usbtmc/driver"
+)
+
+// Per USB488 Table 7, bNotify1 has D7 set for USB488 notifications. A
+// READ_STATUS_BYTE response carries the request's bTag in D6..D0, which is
+// always between 2 and 127, while a service request uses bTag 1.
+const (
+	notifyMask           = 0x80
+	notifyServiceRequest = 0x81
+)
+
+// serviceRequestBuffer is the capacity of the channels returned by
+// ServiceRequests.
+const serviceRequestBuffer = 16
+
+// interruptPollTimeout bounds each Interrupt-IN read of the background reader.
+const interruptPollTimeout = 100 * time.Millisecond
+
+// interruptReader reads the Interrupt-IN endpoint in the background and
+// dispatches each notification either to the service request subscribers or
+// to the READ_STATUS_BYTE request waiting for it.
+type interruptReader struct {
+	mu      sync.Mutex
+	subs    map[chan byte]struct{}
+	pending map[byte]chan byte
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// ServiceRequests returns a channel that receives the status byte of every
+// USB488 service request (SRQ) the device sends on its Interrupt-IN endpoint.
+// The channel is closed when ctx is done or the Device is closed. A
+// background reader is started on the Interrupt-IN endpoint the first time
+// ServiceRequests is called and runs until the Device is closed; while it
+// runs, ReadStatusByte receives its responses through it. If a subscriber
+// falls behind by more than 16 service requests, further service requests are
+// dropped for that subscriber. An error is returned once the Device is closed.
+func (d *Device) ServiceRequests(ctx context.Context) (<-chan byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.usbDevice.HasInterruptIn() {
+		return nil, &UnsupportedError{
+			Op:         "service requests",
+			Capability: "an Interrupt-IN endpoint",
+		}
+	}
+	if d.closed {
+		return nil, errors.New("usbtmc: service requests on closed device")
+	}
+	if d.intr == nil {
+		d.intr = d.startInterruptReader()
+	}
+	r := d.intr
+	ch := make(chan byte, serviceRequestBuffer)
+	r.mu.Lock()
+	r.subs[ch] = struct{}{}
+	r.mu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-r.done:
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.subs[ch]; ok {
+			delete(r.subs, ch)
+			close(ch)
+		}
+	}()
+	return ch, nil
+}
+
+// startInterruptReader starts the background Interrupt-IN reader. The caller
+// must hold d.mu.
+func (d *Device) startInterruptReader() *interruptReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &interruptReader{
+		subs:    make(map[chan byte]struct{}),
+		pending: make(map[byte]chan byte),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		buf := make([]byte, 2)
+		for {
+			// Each read is bounded so that stop returns promptly even with
+			// drivers that only cancel a transfer when it times out.
+			rctx, cancel := context.WithTimeout(ctx, interruptPollTimeout)
+			n, err := d.usbDevice.ReadInterruptContext(rctx, buf)
+			polled := rctx.Err() != nil
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && (polled || errors.Is(err, driver.ErrTimeout)) {
+				continue
+			}
+			if err != nil {
+				debug.Printf("interrupt-in read: %v\n", err)
+				if pollWait(ctx) != nil {
+					return
+				}
+				continue
+			}
+			if n < 2 {
+				debug.Printf("ignoring short interrupt-in notification % x\n", buf[:n])
+				continue
+			}
+			r.dispatch(buf[0], buf[1])
+		}
+	}()
+	return r
+}
+
+// dispatch delivers an Interrupt-IN notification.
+func (r *interruptReader) dispatch(bNotify1, bNotify2 byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case bNotify1 == notifyServiceRequest:
+		for ch := range r.subs {
+			select {
+			case ch <- bNotify2:
+			default:
+				debug.Printf("dropping service request %#02x\n", bNotify2)
+			}
+		}
+	case bNotify1&notifyMask != 0:
+		if ch, ok := r.pending[bNotify1&^notifyMask]; ok {
+			select {
+			case ch <- bNotify2:
+			default:
+			}
+			return
+		}
+		fallthrough
+	default:
+		debug.Printf("ignoring interrupt-in notification %02x %02x\n",
+			bNotify1, bNotify2)
+	}
+}
+
+// expect registers a READ_STATUS_BYTE request with the given bTag and returns
+// the channel its status byte will be delivered on.
+func (r *interruptReader) expect(bTag byte) chan byte {
+	ch := make(chan byte, 1)
+	r.mu.Lock()
+	r.pending[bTag] = ch
+	r.mu.Unlock()
+	return ch
+}
+
+// forget removes the READ_STATUS_BYTE request with the given bTag.
+func (r *interruptReader) forget(bTag byte) {
+	r.mu.Lock()
+	delete(r.pending, bTag)
+	r.mu.Unlock()
+}
+
+// stop stops the background reader and waits for it to exit.
+func (r *interruptReader) stop() {
+	r.cancel()
+	<-r.done
+}
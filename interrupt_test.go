@@ -0,0 +1,108 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestServiceRequests(t *testing.T) {
+	mock := &mockUSBDevice{hasIntr: true, notifies: make(chan []byte, 4)}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srq, err := dev.ServiceRequests(ctx)
+	if err != nil {
+		t.Fatalf("ServiceRequests returned error: %v", err)
+	}
+
+	// A READ_STATUS_BYTE response arriving while the background reader runs
+	// must reach ReadStatusByte rather than the service request channel.
+	mock.reply(readStatusByte, byte(statusSuccess), 2, 0x00)
+	mock.onCtrl = func(c controlCall) {
+		if c.bRequest == uint8(readStatusByte) {
+			mock.notify(0x81, 0x40)
+			mock.notify(0x80|byte(c.wValue), 0x10)
+		}
+	}
+	stb, err := dev.ReadStatusByte(ctx)
+	if err != nil {
+		t.Fatalf("ReadStatusByte returned error: %v", err)
+	}
+	if stb != 0x10 {
+		t.Errorf("status byte = %#02x, want 0x10", stb)
+	}
+
+	select {
+	case stb := <-srq:
+		if stb != 0x40 {
+			t.Errorf("service request status byte = %#02x, want 0x40", stb)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for service request")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-srq:
+		if ok {
+			t.Error("received unexpected service request after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("service request channel not closed after cancel")
+	}
+
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+}
+
+func TestServiceRequestsClosedWithDevice(t *testing.T) {
+	mock := &mockUSBDevice{hasIntr: true, notifies: make(chan []byte)}
+	dev := newTestDevice(mock)
+
+	srq, err := dev.ServiceRequests(context.Background())
+	if err != nil {
+		t.Fatalf("ServiceRequests returned error: %v", err)
+	}
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	select {
+	case _, ok := <-srq:
+		if ok {
+			t.Error("received unexpected service request after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("service request channel not closed after Close")
+	}
+}
+
+func TestServiceRequestsNoInterruptIn(t *testing.T) {
+	dev := newTestDevice(&mockUSBDevice{})
+	_, err := dev.ServiceRequests(context.Background())
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("error = %v, want errors.ErrUnsupported", err)
+	}
+}
+
+func TestServiceRequestsAfterClose(t *testing.T) {
+	mock := &mockUSBDevice{hasIntr: true, notifies: make(chan []byte)}
+	dev := newTestDevice(mock)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if _, err := dev.ServiceRequests(context.Background()); err == nil {
+		t.Fatal("ServiceRequests after Close returned no error")
+	}
+	if dev.intr != nil {
+		t.Error("ServiceRequests after Close started an Interrupt-IN reader")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Per USBTMC Table 9, D0 of the bmTransferAttributes of a DEV_DEP_MSG_IN
+// response is set when the transfer ends the message, and D1 is set when the
+// transfer ends with the TermChar requested in REQUEST_DEV_DEP_MSG_IN.
+const (
+	transferAttrEOM      = 0x01
+	transferAttrTermChar = 0x02
+)
+
+// defaultMaxMessageSize is the largest message ReadMessage accepts unless
+// changed with SetMaxMessageSize.
+const defaultMaxMessageSize = 64 * 1024 * 1024
+
+// readMessageChunk is the number of message bytes requested by each
+// REQUEST_DEV_DEP_MSG_IN that ReadMessage sends, so that each response
+// including its header fits in ioBufferSize.
+const readMessageChunk = ioBufferSize - usbtmcHeaderLen
+
+// ErrMessageTooLarge is returned by ReadMessage when the device's message is
+// larger than the maximum message size set with SetMaxMessageSize.
+var ErrMessageTooLarge = errors.New("usbtmc: message exceeds the maximum message size")
+
+// ReadMessage reads a complete device-dependent message, such as a screenshot
+// or waveform dump, sending successive REQUEST_DEV_DEP_MSG_IN requests until
+// the device sets EOM. The termChar is not used, since binary data may
+// contain it; use ReadMessageWithOptions to end the message at the termChar.
+//
+// If the message is larger than the maximum message size, the data read so
+// far is returned with an error matching ErrMessageTooLarge and the rest of
+// the message is discarded by clearing the device. If ctx is done part way
+// through the message, the data read so far is returned with an *AbortError.
+func (d *Device) ReadMessage(ctx context.Context) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.releaseBuffers()
+	return d.readMessage(ctx, nil, readMessageChunk, ReadOptions{})
+}
+
+// ReadMessageWithOptions reads a complete message like ReadMessage, but if
+// opts enables the termination character, the message also ends at a transfer
+// the device ends with opts.TermChar. A MaxSize greater than zero lowers the
+// maximum message size for this read, and a Timeout greater than zero bounds
+// the read in addition to any deadline of ctx. An *UnsupportedError is
+// returned if opts enables the termination character but the device does not
+// support it.
+func (d *Device) ReadMessageWithOptions(ctx context.Context, opts ReadOptions) ([]byte, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.releaseBuffers()
+	if opts.TermCharEnabled {
+		if err := d.checkTermChar(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return d.readMessage(ctx, nil, readMessageChunk, opts)
+}
+
+// readMessage implements ReadMessage, appending the message to dst. The first
+// REQUEST_DEV_DEP_MSG_IN asks for up to first bytes and the following ones for
+// up to readMessageChunk bytes. The message ends at EOM or, if opts enables
+// the termination character, at a transfer ending with opts.TermChar. The
+// opts.Timeout field is not used. The caller must hold d.mu.
+func (d *Device) readMessage(
+	ctx context.Context,
+	dst []byte,
+	first int,
+	opts ReadOptions,
+) ([]byte, error) {
+	end := byte(transferAttrEOM)
+	if opts.TermCharEnabled {
+		end |= transferAttrTermChar
+	}
+	limit := d.maxMessageSize
+	if limit <= 0 {
+		limit = defaultMaxMessageSize
+	}
+	if opts.MaxSize > 0 {
+		limit = min(limit, opts.MaxSize)
+	}
+
+	start := len(dst)
+	size := first
+	for {
+		read := len(dst) - start
+		if err := ctx.Err(); err != nil && read > 0 {
+			// The device still holds the rest of the message.
+			actx, cancel := abortContext(ctx)
+			defer cancel()
+			if cerr := d.clear(actx); cerr != nil {
+				err = errors.Join(err, cerr)
+			}
+			return dst, &AbortError{Op: "read", N: read, Err: err}
+		}
+		remaining := limit - read
+		if remaining == 0 {
+			err := fmt.Errorf("%w of %d bytes", ErrMessageTooLarge, limit)
+			if cerr := d.clear(ctx); cerr != nil {
+				err = errors.Join(err, cerr)
+			}
+			return dst, err
+		}
+		size = min(size, remaining)
+		dst = slices.Grow(dst, size)
+		n, attr, err := d.readTransfer(
+			ctx, dst[len(dst):len(dst)+size], requestDevDepMsgIn,
+			opts.TermCharEnabled, opts.TermChar)
+		dst = dst[:len(dst)+n]
+		if err != nil {
+			var ae *AbortError
+			if errors.As(err, &ae) {
+				ae.N = len(dst) - start
+			}
+			return dst, err
+		}
+		if attr&end != 0 {
+			return dst, nil
+		}
+		if n == 0 {
+			return dst, errors.New("usbtmc: device sent an empty transfer without EOM")
+		}
+		size = readMessageChunk
+	}
+}
+
+// SetMaxMessageSize sets the largest message in bytes that ReadMessage and
+// Query accept. A size of zero or less restores the default of 64 MB.
+func (d *Device) SetMaxMessageSize(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxMessageSize = n
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// buildPartialMsgInResponse builds a DEV_DEP_MSG_IN response that does not
+// end the message.
+func buildPartialMsgInResponse(bTag byte, payload []byte) []byte {
+	resp := buildDevDepMsgInResponse(bTag, payload)
+	resp[8] = 0x00
+	return resp
+}
+
+func TestReadMessageUntilEOM(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	mock.reads = [][]byte{
+		buildPartialMsgInResponse(1, []byte("#18")),
+		buildPartialMsgInResponse(2, []byte("abcd")),
+		buildDevDepMsgInResponse(3, []byte("efgh\n")),
+	}
+
+	msg, err := dev.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatalf("ReadMessage returned error: %v", err)
+	}
+	if got, want := string(msg), "#18abcdefgh\n"; got != want {
+		t.Errorf("ReadMessage = %q, want %q", got, want)
+	}
+	if len(mock.writes) != 3 {
+		t.Fatalf("expected 3 REQUEST_DEV_DEP_MSG_IN, got %d", len(mock.writes))
+	}
+	for i, w := range mock.writes {
+		if w[0] != byte(requestDevDepMsgIn) || w[1] != byte(i+1) {
+			t.Errorf("request %d header = % x, want REQUEST_DEV_DEP_MSG_IN bTag %d",
+				i, w[:4], i+1)
+		}
+	}
+}
+
+func TestReadMessageWithOptionsTermChar(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(getCapabilities, capabilitiesResponse(0x00, 0x01, 0x00, 0x00)...)
+	dev := newTestDevice(mock)
+	resp := buildPartialMsgInResponse(1, []byte("1.25\n"))
+	resp[8] = transferAttrTermChar
+	mock.reads = [][]byte{resp}
+
+	msg, err := dev.ReadMessageWithOptions(context.Background(),
+		ReadOptions{TermChar: '\n', TermCharEnabled: true})
+	if err != nil {
+		t.Fatalf("ReadMessageWithOptions returned error: %v", err)
+	}
+	if string(msg) != "1.25\n" {
+		t.Errorf("ReadMessageWithOptions = %q, want %q", msg, "1.25\n")
+	}
+	if w := mock.writes[0]; w[8] != 0x02 || w[9] != '\n' {
+		t.Errorf("request did not enable termChar: % x", w)
+	}
+}
+
+func TestReadMessageBinaryBlockWithTermChar(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	// The block contains '\n', and the device ends the first transfer there
+	// with the TermChar attribute but not EOM.
+	first := buildPartialMsgInResponse(1, []byte("#16\x89PN\n"))
+	first[8] = transferAttrTermChar
+	mock.reads = [][]byte{first, buildDevDepMsgInResponse(2, []byte("\x1a\n\n"))}
+
+	msg, err := dev.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatalf("ReadMessage returned error: %v", err)
+	}
+	if want := "#16\x89PN\n\x1a\n\n"; string(msg) != want {
+		t.Errorf("ReadMessage = %q, want %q", msg, want)
+	}
+	for i, w := range mock.writes {
+		if w[8]&transferAttrTermChar != 0 {
+			t.Errorf("request %d asked the device to end on the termChar", i)
+		}
+	}
+}
+
+func TestReadMessageTooLarge(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	dev.SetMaxMessageSize(6)
+	mock.reads = [][]byte{
+		buildPartialMsgInResponse(1, []byte("abcd")),
+		buildPartialMsgInResponse(2, []byte("ef")),
+	}
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusSuccess), 0)
+
+	msg, err := dev.ReadMessage(context.Background())
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("error = %v, want ErrMessageTooLarge", err)
+	}
+	if string(msg) != "abcdef" {
+		t.Errorf("ReadMessage = %q, want %q", msg, "abcdef")
+	}
+	// The second request only asks for what is left under the limit.
+	if size := binary.LittleEndian.Uint32(mock.writes[1][4:8]); size != 2 {
+		t.Errorf("second request transfer size = %d, want 2", size)
+	}
+	want := []uint8{uint8(initiateClear), uint8(checkClearStatus), requestClearFeature}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
+
+func TestReadMessageCancelBetweenTransfers(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mock.reads = [][]byte{buildPartialMsgInResponse(1, []byte("abcd"))}
+	mock.onRead = func(int) error {
+		cancel()
+		return nil
+	}
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusSuccess), 0)
+
+	msg, err := dev.ReadMessage(ctx)
+	var ae *AbortError
+	if !errors.As(err, &ae) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want *AbortError wrapping context.Canceled", err)
+	}
+	if string(msg) != "abcd" || ae.N != 4 {
+		t.Errorf("ReadMessage = %q (AbortError.N %d), want %q", msg, ae.N, "abcd")
+	}
+	want := []uint8{uint8(initiateClear), uint8(checkClearStatus), requestClearFeature}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"time"
+)
+
+// ReadOptions configures a single ReadWithOptions or ReadMessageWithOptions
+// call.
+type ReadOptions struct {
+	// TermChar is the termination character after which the device ends the
+	// transfer when TermCharEnabled is set.
+	TermChar byte
+	// TermCharEnabled asks the device to end the transfer after TermChar.
+	// The device must report TermChar support in its GET_CAPABILITIES
+	// response.
+	TermCharEnabled bool
+	// MaxSize limits the response to MaxSize bytes when it is greater than
+	// zero and less than the length of the read buffer.
+	MaxSize int
+	// Timeout bounds the read when it is greater than zero, in addition to
+	// any deadline of the read's context.
+	Timeout time.Duration
+}
+
+// DefaultReadOptions returns the ReadOptions matching the Device's current
+// termination character settings, as used by Read.
+func (d *Device) DefaultReadOptions() ReadOptions {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return ReadOptions{TermChar: d.termChar, TermCharEnabled: d.termCharEnabled}
+}
+
+// ReadWithOptions reads from the device like ReadBinary but with the
+// termination character, maximum response size, and timeout given by opts
+// instead of the Device's defaults. An *UnsupportedError is returned if opts
+// enables the termination character but the device does not support it.
+func (d *Device) ReadWithOptions(ctx context.Context, p []byte, opts ReadOptions) (int, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if opts.MaxSize > 0 && opts.MaxSize < len(p) {
+		p = p[:opts.MaxSize]
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.releaseBuffers()
+	if opts.TermCharEnabled {
+		if err := d.checkTermChar(ctx); err != nil {
+			return 0, err
+		}
+	}
+	n, _, err := d.readTransfer(ctx, p, requestDevDepMsgIn,
+		opts.TermCharEnabled, opts.TermChar)
+	return n, err
+}
+
+// SetTermChar sets the termination character that Read asks the device to
+// end transfers with, and that Command and Query append to each command.
+// Query reads its reply until EOM whatever the termination character. The
+// default is '\n'.
+func (d *Device) SetTermChar(c byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.termChar = c
+}
+
+// SetTermCharEnabled sets whether Read asks the device to end transfers with
+// the termination character. It is enabled by default. Query and ReadMessage
+// read until EOM whatever this setting.
+// Enabling it returns an *UnsupportedError if the device's GET_CAPABILITIES
+// response shows that it does not support a termination character.
+func (d *Device) SetTermCharEnabled(ctx context.Context, enabled bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if enabled {
+		if err := d.checkTermChar(ctx); err != nil {
+			return err
+		}
+	}
+	d.termCharEnabled = enabled
+	return nil
+}
+
+// checkTermChar returns an *UnsupportedError if the device does not support
+// a termination character. The caller must hold d.mu.
+func (d *Device) checkTermChar(ctx context.Context) error {
+	caps, err := d.capabilities(ctx)
+	if err != nil {
+		return err
+	}
+	if !caps.TermChar {
+		return &UnsupportedError{Op: "termination character", Capability: "TermChar"}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadWithOptions(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(getCapabilities, capabilitiesResponse(0x00, 0x01, 0x00, 0x00)...)
+	mock.reads = [][]byte{buildDevDepMsgInResponse(1, []byte("1,2;"))}
+	dev := newTestDevice(mock)
+
+	buf := make([]byte, 100)
+	n, err := dev.ReadWithOptions(context.Background(), buf, ReadOptions{
+		TermChar:        ';',
+		TermCharEnabled: true,
+		MaxSize:         10,
+	})
+	if err != nil {
+		t.Fatalf("ReadWithOptions returned error: %v", err)
+	}
+	if string(buf[:n]) != "1,2;" {
+		t.Errorf("ReadWithOptions = %q, want %q", buf[:n], "1,2;")
+	}
+	w := mock.writes[0]
+	if size := binary.LittleEndian.Uint32(w[4:8]); size != 10 {
+		t.Errorf("request transfer size = %d, want 10", size)
+	}
+	if w[8] != 0x02 || w[9] != ';' {
+		t.Errorf("request attributes = %#02x %q, want 0x02 ';'", w[8], w[9])
+	}
+}
+
+func TestReadWithOptionsTermCharUnsupported(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(getCapabilities, capabilitiesResponse(0x00, 0x00, 0x00, 0x00)...)
+	dev := newTestDevice(mock)
+
+	_, err := dev.ReadWithOptions(context.Background(), make([]byte, 100),
+		ReadOptions{TermChar: '\n', TermCharEnabled: true})
+	var ue *UnsupportedError
+	if !errors.As(err, &ue) || !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("error = %v, want *UnsupportedError", err)
+	}
+	if len(mock.writes) != 0 {
+		t.Errorf("sent %d Bulk-OUT transfers, want 0", len(mock.writes))
+	}
+}
+
+func TestReadWithOptionsTimeout(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+
+	_, err := dev.ReadWithOptions(context.Background(), make([]byte, 100),
+		ReadOptions{Timeout: time.Nanosecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestTermCharSetters(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(getCapabilities, capabilitiesResponse(0x00, 0x00, 0x00, 0x00)...)
+	mock.reads = [][]byte{buildDevDepMsgInResponse(2, []byte("ok"))}
+	dev := newTestDevice(mock)
+	ctx := context.Background()
+
+	dev.SetTermChar('\r')
+	if err := dev.SetTermCharEnabled(ctx, false); err != nil {
+		t.Fatalf("SetTermCharEnabled(false) returned error: %v", err)
+	}
+	if err := dev.SetTermCharEnabled(ctx, true); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("SetTermCharEnabled(true) error = %v, want ErrUnsupported", err)
+	}
+	if want := (ReadOptions{TermChar: '\r'}); dev.DefaultReadOptions() != want {
+		t.Errorf("DefaultReadOptions = %+v, want %+v", dev.DefaultReadOptions(), want)
+	}
+
+	if _, err := dev.Query(ctx, "*OPC?"); err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	cmd := mock.writes[0]
+	if got := string(cmd[bulkOutHeaderSize : bulkOutHeaderSize+6]); got != "*OPC?\r" {
+		t.Errorf("command = %q, want %q", got, "*OPC?\r")
+	}
+	if req := mock.writes[1]; req[8] != 0x00 {
+		t.Errorf("request attributes = %#02x, want 0", req[8])
+	}
+}
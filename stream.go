@@ -0,0 +1,170 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// MessageWriter streams a single device-dependent message to a Device. It
+// implements io.WriteCloser: the bytes of every Write are sent in transfers
+// without EOM, and Close sends the final transfer with EOM set, ending the
+// message.
+type MessageWriter struct {
+	d      *Device
+	ctx    context.Context
+	buf    []byte
+	sent   int
+	err    error
+	closed bool
+}
+
+// NewMessageWriter returns a MessageWriter that streams one message to the
+// device, such as a waveform read from a file with io.Copy. The Device is
+// locked until the MessageWriter is closed, so other Device methods block
+// until then and must not be called from the goroutine using the
+// MessageWriter. If ctx is done part way through the message, the transfer
+// is aborted on the device as with WriteBinary.
+func (d *Device) NewMessageWriter(ctx context.Context) *MessageWriter {
+	d.mu.Lock()
+	return &MessageWriter{
+		d:   d,
+		ctx: ctx,
+		buf: make([]byte, 0, d.transferSize()-bulkOutHeaderSize),
+	}
+}
+
+// Write buffers p and sends every full transfer to the device with EOM
+// cleared. At least one byte is always held back so that Close has data to
+// send with EOM set.
+func (w *MessageWriter) Write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, errors.New("usbtmc: write to closed MessageWriter")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close sends the remaining bytes with EOM set and unlocks the Device. If no
+// bytes were written, nothing is sent.
+func (w *MessageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.d.mu.Unlock()
+	if w.err == nil && len(w.buf) > 0 {
+		w.flush(true) //nolint:errcheck // The error is kept in w.err.
+	}
+	return w.err
+}
+
+// flush sends the buffered bytes in one transfer.
+func (w *MessageWriter) flush(eom bool) error {
+	encode := encodeBulkOutHeader
+	if !eom {
+		encode = func(bTag byte, transferSize uint32, _ bool) [12]byte {
+			return encodeBulkOutHeader(bTag, transferSize, false)
+		}
+	}
+	n, err := w.d.writeTransfers(w.ctx, w.buf, encode)
+	if err != nil && w.sent > 0 && timedOut(w.ctx, err) {
+		// Earlier transfers left a partial message on the device.
+		actx, cancel := abortContext(w.ctx)
+		defer cancel()
+		if cerr := w.d.clear(actx); cerr != nil {
+			err = errors.Join(err, cerr)
+		}
+	}
+	w.sent += n
+	w.buf = w.buf[:0]
+	w.err = err
+	return err
+}
+
+// MessageReader streams a single device-dependent message from a Device. It
+// implements io.ReadCloser and returns io.EOF once the transfer carrying EOM
+// has been read.
+type MessageReader struct {
+	d       *Device
+	ctx     context.Context
+	started bool
+	eom     bool
+	closed  bool
+}
+
+// NewMessageReader returns a MessageReader that streams one message from the
+// device, such as a screenshot written to a file with io.Copy. Each Read sends
+// a REQUEST_DEV_DEP_MSG_IN for up to len(p) bytes without a termChar. The
+// Device is locked until the MessageReader is closed, so other Device methods
+// block until then and must not be called from the goroutine using the
+// MessageReader.
+func (d *Device) NewMessageReader(ctx context.Context) *MessageReader {
+	d.mu.Lock()
+	return &MessageReader{d: d, ctx: ctx}
+}
+
+// Read reads the next part of the message into p. It returns io.EOF at the
+// end of the message.
+func (r *MessageReader) Read(p []byte) (n int, err error) {
+	if r.closed {
+		return 0, errors.New("usbtmc: read from closed MessageReader")
+	}
+	if r.eom {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	r.started = true
+	n, attr, err := r.d.readTransfer(r.ctx, p, requestDevDepMsgIn, false, 0)
+	if err != nil {
+		return n, err
+	}
+	if attr&transferAttrEOM != 0 {
+		r.eom = true
+		if n == 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+	if n == 0 {
+		return 0, errors.New("usbtmc: device sent an empty transfer without EOM")
+	}
+	return n, nil
+}
+
+// Close unlocks the Device. If the message was only partly read, the device
+// is cleared to discard the rest of it.
+func (r *MessageReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	defer r.d.mu.Unlock()
+	defer r.d.releaseBuffers()
+	if !r.started || r.eom {
+		return nil
+	}
+	ctx, cancel := abortContext(r.ctx)
+	defer cancel()
+	return r.d.clear(ctx)
+}
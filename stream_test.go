@@ -0,0 +1,125 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestMessageWriter(t *testing.T) {
+	mock := &mockUSBDevice{packet: 64}
+	dev := newTestDevice(mock)
+	dev.SetMaxTransferSize(64)
+
+	data := make([]byte, 130)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	w := dev.NewMessageWriter(context.Background())
+	// Write in pieces that do not line up with the 52-byte transfers.
+	for _, chunk := range [][]byte{data[:10], data[10:104], data[104:]} {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	// The last 26 bytes are held back for the EOM transfer.
+	if len(mock.writes) != 2 {
+		t.Errorf("sent %d transfers before Close, want 2", len(mock.writes))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	var got []byte
+	wantEOM := []byte{0, 0, 1}
+	if len(mock.writes) != len(wantEOM) {
+		t.Fatalf("sent %d transfers, want %d", len(mock.writes), len(wantEOM))
+	}
+	for i, tr := range mock.writes {
+		if tr[8] != wantEOM[i] {
+			t.Errorf("transfer %d EOM = %d, want %d", i, tr[8], wantEOM[i])
+		}
+		size := int(binary.LittleEndian.Uint32(tr[4:8]))
+		got = append(got, tr[bulkOutHeaderSize:bulkOutHeaderSize+size]...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("message = % x, want % x", got, data)
+	}
+
+	// The Device is unlocked again once the writer is closed.
+	if _, err := dev.WriteStringContext(context.Background(), "*OPC\n"); err != nil {
+		t.Errorf("WriteStringContext after Close returned error: %v", err)
+	}
+}
+
+func TestMessageWriterEmpty(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+
+	w := dev.NewMessageWriter(context.Background())
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if len(mock.writes) != 0 {
+		t.Errorf("empty message sent %d transfers, want 0", len(mock.writes))
+	}
+}
+
+func TestMessageReader(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	mock.reads = [][]byte{
+		buildPartialMsgInResponse(1, []byte("abcd")),
+		buildPartialMsgInResponse(2, []byte("efgh")),
+		buildDevDepMsgInResponse(3, []byte("ij")),
+	}
+
+	r := dev.NewMessageReader(context.Background())
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll returned error: %v", err)
+	}
+	if string(got) != "abcdefghij" {
+		t.Errorf("message = %q, want %q", got, "abcdefghij")
+	}
+	if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("Read after EOM = %d, %v, want 0, io.EOF", n, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if len(mock.controls) != 0 {
+		t.Errorf("Close after EOM sent %d control requests, want 0", len(mock.controls))
+	}
+	// Binary streams do not ask the device to stop at the termChar.
+	if mock.writes[0][8] != 0 {
+		t.Errorf("REQUEST_DEV_DEP_MSG_IN attributes = %#02x, want 0", mock.writes[0][8])
+	}
+}
+
+func TestMessageReaderCloseEarly(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	mock.reads = [][]byte{buildPartialMsgInResponse(1, []byte("abcd"))}
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusSuccess), 0)
+
+	r := dev.NewMessageReader(context.Background())
+	if _, err := r.Read(make([]byte, 100)); err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	want := []uint8{uint8(initiateClear), uint8(checkClearStatus), requestClearFeature}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// TruncationPolicy determines what a Device does with response data that
+// does not fit in the buffer passed to a read.
+type TruncationPolicy int
+
+const (
+	// TruncationBuffer keeps the rest of the transfer and returns it from the
+	// following reads before another request is sent to the device. This is
+	// the default.
+	TruncationBuffer TruncationPolicy = iota
+	// TruncationDiscard discards the rest of the transfer, clearing the
+	// device if the message continues beyond it.
+	TruncationDiscard
+)
+
+// TruncatedError is returned along with the data that fit when a device
+// sends more data than the buffer passed to a read can hold.
+type TruncatedError struct {
+	// N is the number of bytes returned to the caller.
+	N int
+	// Remaining is the number of bytes of the transfer that did not fit.
+	Remaining int
+	// Discarded reports whether the remaining bytes were discarded rather
+	// than kept for the next read.
+	Discarded bool
+}
+
+func (e *TruncatedError) Error() string {
+	action := "kept for the next read"
+	if e.Discarded {
+		action = "discarded"
+	}
+	return fmt.Sprintf("usbtmc: response truncated after %d bytes: %d bytes %s",
+		e.N, e.Remaining, action)
+}
+
+// SetTruncationPolicy sets what the Device does with response data that does
+// not fit in the buffer passed to a read. Either way, the read returns a
+// *TruncatedError so that the truncation is never silent. Data kept with
+// TruncationBuffer is discarded once a new message is written to the device.
+func (d *Device) SetTruncationPolicy(policy TruncationPolicy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.truncation = policy
+}
+
+// readRemainder reads the rest of a Bulk-IN transfer that did not fit in the
+// caller's buffer, starting with the extra bytes that already arrived, until
+// the want message bytes left in the transfer have arrived or the device sends
+// a short packet. The transfer is read in chunks of at most ioBufferSize bytes
+// through the Device's reused read buffer, since want comes from the device.
+// With TruncationBuffer the bytes are kept in d.pending; otherwise they are
+// only counted. Alignment bytes are dropped. It returns the number of bytes
+// read. The caller must hold d.mu.
+func (d *Device) readRemainder(ctx context.Context, extra []byte, want int) (int, error) {
+	keep := d.truncation == TruncationBuffer
+	n := min(len(extra), want)
+	if keep {
+		// extra shares the Device's reused read buffer.
+		d.pending = append([]byte(nil), extra[:n]...)
+	}
+	packetSize := d.bulkInPacketSize()
+	for n < want {
+		size := min(want-n, ioBufferSize)
+		if m := size % packetSize; m != 0 {
+			size += packetSize - m
+		}
+		buf := growBuffer(&d.rbuf, size)
+		got, err := d.usbDevice.ReadContext(ctx, buf)
+		if err != nil {
+			d.pending = nil
+			return n, err
+		}
+		if keep {
+			d.pending = append(d.pending, buf[:min(got, want-n)]...)
+		}
+		n += min(got, want-n)
+		if got%packetSize != 0 || got == 0 {
+			break
+		}
+	}
+	return n, nil
+}
+
+// truncated applies the truncation policy to the remaining bytes of a
+// transfer of the given msgID that did not fit in the caller's buffer after n
+// bytes, which readRemainder has already read, and returns the resulting
+// *TruncatedError. The caller must hold d.mu.
+func (d *Device) truncated(
+	ctx context.Context,
+	id msgID,
+	n int,
+	remaining int,
+	transferAttr byte,
+) error {
+	te := &TruncatedError{N: n, Remaining: remaining}
+	if d.truncation == TruncationBuffer {
+		d.pendingID, d.pendingAttr = id, transferAttr
+		return te
+	}
+	te.Discarded = true
+	if id == requestDevDepMsgIn && transferAttr&transferAttrEOM == 0 {
+		// The device still holds the rest of the message.
+		if err := d.clear(ctx); err != nil {
+			return errors.Join(te, err)
+		}
+	}
+	return te
+}
+
+// readPending copies data kept by TruncationBuffer into p. The caller must
+// hold d.mu.
+func (d *Device) readPending(p []byte) (int, byte, error) {
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	if len(d.pending) > 0 {
+		return n, 0, nil
+	}
+	d.pending = nil
+	return n, d.pendingAttr, nil
+}
+
+// discardPending drops data kept by TruncationBuffer. The caller must hold
+// d.mu.
+func (d *Device) discardPending() {
+	if len(d.pending) > 0 {
+		debug.Printf("discarding %d bytes of truncated response\n", len(d.pending))
+	}
+	d.pending = nil
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// splitPackets splits a Bulk-IN transfer into packets of the given size.
+func splitPackets(resp []byte, size int) [][]byte {
+	var packets [][]byte
+	for len(resp) > size {
+		packets = append(packets, resp[:size])
+		resp = resp[size:]
+	}
+	return append(packets, resp)
+}
+
+func TestReadTruncatedBuffer(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	mock.reads = [][]byte{
+		buildDevDepMsgInResponse(1, []byte("0123456789abcdefghij")),
+		buildDevDepMsgInResponse(2, []byte("next")),
+	}
+	ctx := context.Background()
+	buf := make([]byte, 8)
+
+	n, err := dev.ReadBinary(ctx, buf)
+	var te *TruncatedError
+	if !errors.As(err, &te) {
+		t.Fatalf("error = %v, want *TruncatedError", err)
+	}
+	if n != 8 || te.N != 8 || te.Remaining != 12 || te.Discarded {
+		t.Errorf("n = %d, error = %+v, want 8 bytes with 12 kept", n, te)
+	}
+	if string(buf[:n]) != "01234567" {
+		t.Errorf("data = %q, want %q", buf[:n], "01234567")
+	}
+
+	// The kept bytes are returned without another request.
+	for _, want := range []string{"89abcdef", "ghij"} {
+		n, err = dev.ReadBinary(ctx, buf)
+		if err != nil {
+			t.Fatalf("ReadBinary returned error: %v", err)
+		}
+		if string(buf[:n]) != want {
+			t.Errorf("data = %q, want %q", buf[:n], want)
+		}
+	}
+	if len(mock.writes) != 1 {
+		t.Errorf("sent %d requests, want 1", len(mock.writes))
+	}
+
+	n, err = dev.ReadBinary(ctx, buf)
+	if err != nil || string(buf[:n]) != "next" {
+		t.Errorf("ReadBinary = %q, %v, want %q", buf[:n], err, "next")
+	}
+}
+
+func TestReadTruncatedMultiPacket(t *testing.T) {
+	mock := &mockUSBDevice{packet: 64}
+	dev := newTestDevice(mock)
+	payload := make([]byte, 150)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	mock.reads = splitPackets(buildDevDepMsgInResponse(1, payload), 64)
+	mock.reads = append(mock.reads, buildDevDepMsgInResponse(2, []byte("next")))
+	ctx := context.Background()
+
+	buf := make([]byte, 10)
+	_, err := dev.ReadBinary(ctx, buf)
+	var te *TruncatedError
+	if !errors.As(err, &te) || te.Remaining != 140 {
+		t.Fatalf("error = %v, want *TruncatedError with 140 bytes remaining", err)
+	}
+	for i, n := range mock.readLens {
+		if n%64 != 0 {
+			t.Errorf("read %d used a %d-byte buffer, want a multiple of 64", i, n)
+		}
+	}
+
+	rest := make([]byte, 200)
+	n, err := dev.ReadBinary(ctx, rest)
+	if err != nil {
+		t.Fatalf("ReadBinary returned error: %v", err)
+	}
+	if !bytes.Equal(rest[:n], payload[10:]) {
+		t.Errorf("kept data = % x, want % x", rest[:n], payload[10:])
+	}
+	n, err = dev.ReadBinary(ctx, rest)
+	if err != nil || string(rest[:n]) != "next" {
+		t.Errorf("ReadBinary = %q, %v, want %q", rest[:n], err, "next")
+	}
+}
+
+func TestReadTruncatedDiscard(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	dev.SetTruncationPolicy(TruncationDiscard)
+	// The transfer does not end the message, so the device is cleared.
+	mock.reads = [][]byte{
+		buildPartialMsgInResponse(1, []byte("0123456789")),
+		buildDevDepMsgInResponse(2, []byte("next")),
+	}
+	mock.reply(initiateClear, byte(statusSuccess))
+	mock.reply(checkClearStatus, byte(statusSuccess), 0)
+	dev.startTag = 1
+
+	buf := make([]byte, 4)
+	_, err := dev.ReadBinary(context.Background(), buf)
+	var te *TruncatedError
+	if !errors.As(err, &te) || !te.Discarded || te.Remaining != 6 {
+		t.Fatalf("error = %v, want discarded *TruncatedError", err)
+	}
+	want := []uint8{uint8(initiateClear), uint8(checkClearStatus), requestClearFeature}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+
+	// The clear reset the bTag sequence to 1, so the next request uses bTag 2.
+	n, err := dev.ReadBinary(context.Background(), buf)
+	if err != nil || string(buf[:n]) != "next" {
+		t.Errorf("ReadBinary = %q, %v, want %q", buf[:n], err, "next")
+	}
+}
+
+func TestReadTruncatedDiscardLargeTransfer(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	dev.SetTruncationPolicy(TruncationDiscard)
+	// The rest of the transfer spans several bounded reads.
+	payload := make([]byte, 2*ioBufferSize+100)
+	resp := buildDevDepMsgInResponse(1, payload)
+	mock.reads = append([][]byte{resp[:defaultMaxPacketSize]},
+		splitPackets(resp[defaultMaxPacketSize:], ioBufferSize)...)
+	mock.reads = append(mock.reads, buildDevDepMsgInResponse(2, []byte("next")))
+
+	buf := make([]byte, 10)
+	_, err := dev.ReadBinary(context.Background(), buf)
+	var te *TruncatedError
+	if !errors.As(err, &te) || !te.Discarded || te.Remaining != len(payload)-10 {
+		t.Fatalf("error = %v, want discarded *TruncatedError with %d bytes remaining",
+			err, len(payload)-10)
+	}
+	if got := len(mock.readLens); got != 3 {
+		t.Errorf("got %d reads, want 3", got)
+	}
+	for i, n := range mock.readLens {
+		if n > ioBufferSize {
+			t.Errorf("read %d used a %d-byte buffer, want at most %d", i, n, ioBufferSize)
+		}
+	}
+	if dev.pending != nil {
+		t.Errorf("kept %d bytes of a discarded transfer", len(dev.pending))
+	}
+
+	n, err := dev.ReadBinary(context.Background(), buf)
+	if err != nil || string(buf[:n]) != "next" {
+		t.Errorf("ReadBinary = %q, %v, want %q", buf[:n], err, "next")
+	}
+}
+
+func TestWriteDiscardsTruncatedData(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+	mock.reads = [][]byte{
+		buildDevDepMsgInResponse(1, []byte("0123456789")),
+		buildDevDepMsgInResponse(3, []byte("+1.0\n")),
+	}
+	ctx := context.Background()
+
+	if _, err := dev.ReadBinary(ctx, make([]byte, 4)); err == nil {
+		t.Fatal("ReadBinary did not report truncation")
+	}
+	result, err := dev.Query(ctx, "VOLT?")
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if result != "+1.0\n" {
+		t.Errorf("Query = %q, want %q", result, "+1.0\n")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// UnsupportedError is returned when the device's GET_CAPABILITIES response
+// shows that it does not support the requested operation. It matches
+// errors.ErrUnsupported when tested with errors.Is.
+type UnsupportedError struct {
+	// Op is the operation that was attempted.
+	Op string
+	// Capability is the capability the device lacks.
+	Capability string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("usbtmc: %s requires %s, which the device does not support",
+		e.Op, e.Capability)
+}
+
+// Is reports whether target is errors.ErrUnsupported.
+func (e *UnsupportedError) Is(target error) bool {
+	return target == errors.ErrUnsupported
+}
+
+// ReadStatusByte returns the IEEE 488 status byte using the USB488
+// READ_STATUS_BYTE request, without sending "*STB?" through the device's
+// message parser. When the USBTMC interface has an Interrupt-IN endpoint, the
+// device delivers the status byte in an Interrupt-IN notification rather than
+// in the control response, as required by the USB488 specification.
+func (d *Device) ReadStatusByte(ctx context.Context) (byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statusTag = nextStatusTag(d.statusTag)
+	tag := d.statusTag
+
+	// If the background Interrupt-IN reader is running, it owns the endpoint
+	// and hands the notification over.
+	var notified chan byte
+	if d.intr != nil {
+		notified = d.intr.expect(tag)
+		defer d.intr.forget(tag)
+	}
+
+	// Per USB488 Table 12, the response is the USBTMC_status, the bTag, and
+	// the status byte. The status byte field is reserved when the interface
+	// has an Interrupt-IN endpoint.
+	resp, err := d.controlIn(ctx, readStatusByte, uint16(tag), 3)
+	if err != nil {
+		return 0, err
+	}
+	if resp[1] != tag {
+		return 0, fmt.Errorf(
+			"usbtmc: READ_STATUS_BYTE bTag mismatch: got %d, want %d",
+			resp[1], tag)
+	}
+	if !d.usbDevice.HasInterruptIn() {
+		return resp[2], nil
+	}
+	if notified != nil {
+		select {
+		case stb := <-notified:
+			return stb, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	// Per USB488 Table 7, the notification is bNotify1, which has D7 set and
+	// the bTag in D6..D0, followed by bNotify2, which is the status byte.
+	// Anything else on the Interrupt-IN endpoint, such as a service request
+	// notification, is not the response we are waiting for.
+	buf := make([]byte, 2)
+	for {
+		n, err := d.usbDevice.ReadInterruptContext(ctx, buf)
+		if err != nil {
+			return 0, err
+		}
+		if n == 2 && buf[0] == notifyMask|tag {
+			return buf[1], nil
+		}
+		debug.Printf("ignoring interrupt-in notification % x\n", buf[:n])
+	}
+}
+
+// SetRemoteEnable asserts or deasserts the USB488 remote enable (REN) using
+// the REN_CONTROL request. Asserting REN places the device in remote on its
+// next message, and deasserting REN returns it to local and cancels any local
+// lockout. An *UnsupportedError is returned if the interface does not support
+// RL1 remote/local control.
+func (d *Device) SetRemoteEnable(ctx context.Context, enable bool) error {
+	var wValue uint16
+	if enable {
+		wValue = 1
+	}
+	return d.remoteLocal(ctx, "REN_CONTROL", renControl, wValue)
+}
+
+// GoToLocal returns the device to local using the USB488 GO_TO_LOCAL request,
+// re-enabling its front panel controls while REN remains asserted. An
+// *UnsupportedError is returned if the interface does not support RL1
+// remote/local control.
+func (d *Device) GoToLocal(ctx context.Context) error {
+	return d.remoteLocal(ctx, "GO_TO_LOCAL", goToLocal, 0)
+}
+
+// LocalLockout disables the device's front panel controls, including its
+// return-to-local key, using the USB488 LOCAL_LOCKOUT request. The lockout
+// lasts until REN is deasserted with SetRemoteEnable. An *UnsupportedError is
+// returned if the interface does not support RL1 remote/local control.
+func (d *Device) LocalLockout(ctx context.Context) error {
+	return d.remoteLocal(ctx, "LOCAL_LOCKOUT", localLockout, 0)
+}
+
+// remoteLocal sends one of the USB488 remote/local requests after checking
+// the interface capabilities. Some devices report RL1 in the device
+// capabilities without setting the interface's remote/local bit, so either
+// one is taken as support for the requests.
+func (d *Device) remoteLocal(
+	ctx context.Context,
+	op string,
+	req bRequest,
+	wValue uint16,
+) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	caps, err := d.capabilities(ctx)
+	if err != nil {
+		return err
+	}
+	if !caps.RL1 && !caps.RemoteLocal {
+		return &UnsupportedError{Op: op, Capability: "RL1"}
+	}
+	// Per USB488 Tables 16, 18, and 20, the response is the USBTMC_status.
+	_, err = d.controlIn(ctx, req, wValue, 1)
+	return err
+}
+
+// Trigger sends the USB488 TRIGGER message, which triggers the device in the
+// same way as the "*TRG" command but without going through the device's
+// message parser. If trigger fallback is enabled with SetTriggerFallback and
+// the device's capabilities show that it is not DT1 capable or does not accept
+// the TRIGGER message, "*TRG" is sent instead.
+func (d *Device) Trigger(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.triggerFallback {
+		caps, err := d.capabilities(ctx)
+		if err != nil {
+			return err
+		}
+		if !caps.Trigger || !caps.DT1 {
+			_, err = d.writeBinary(ctx, []byte("*TRG"+string(d.termChar)))
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.bTag = nextbTag(d.bTag)
+	header := encodeTriggerHeader(d.bTag)
+	if _, err := d.usbDevice.WriteContext(ctx, header[:]); err != nil {
+		if timedOut(ctx, err) {
+			_, err = d.abortWrite(ctx, errors.Join(ctx.Err(), err), d.bTag,
+				0, 0, true, true)
+			return err
+		}
+		return d.recoverStall(ctx, d.usbDevice.BulkOutEndpointAddress(), err)
+	}
+	return nil
+}
+
+// SetTriggerFallback sets whether Trigger falls back to sending "*TRG" when
+// the device's capabilities show that it is not DT1 capable and therefore
+// does not accept the USB488 TRIGGER message. Fallback is disabled by
+// default, in which case Trigger always sends the TRIGGER message.
+func (d *Device) SetTriggerFallback(enable bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.triggerFallback = enable
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2015-2026 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gotmc/usbtmc/driver"
+)
+
+func TestReadStatusByteControlResponse(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(readStatusByte, byte(statusSuccess), 2, 0x40)
+	dev := newTestDevice(mock)
+
+	stb, err := dev.ReadStatusByte(context.Background())
+	if err != nil {
+		t.Fatalf("ReadStatusByte returned error: %v", err)
+	}
+	if stb != 0x40 {
+		t.Errorf("status byte = %#02x, want 0x40", stb)
+	}
+	c := mock.controls[0]
+	if c.bmRequestType != 0xa1 || c.wValue != 2 || c.wLength != 3 {
+		t.Errorf("setup = %+v, want 0xa1, bTag 2, wLength 3", c)
+	}
+}
+
+func TestReadStatusByteInterruptIn(t *testing.T) {
+	mock := &mockUSBDevice{hasIntr: true, notifies: make(chan []byte, 4)}
+	mock.reply(readStatusByte, byte(statusSuccess), 2, 0x00)
+	mock.reply(readStatusByte, byte(statusSuccess), 3, 0x00)
+	mock.notify(0x81, 0x50) // service request, not our response
+	mock.notify(0x82, 0x10)
+	mock.notify(0x83, 0x20)
+	dev := newTestDevice(mock)
+
+	for _, want := range []byte{0x10, 0x20} {
+		stb, err := dev.ReadStatusByte(context.Background())
+		if err != nil {
+			t.Fatalf("ReadStatusByte returned error: %v", err)
+		}
+		if stb != want {
+			t.Errorf("status byte = %#02x, want %#02x", stb, want)
+		}
+	}
+}
+
+func TestReadStatusByteTagMismatch(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(readStatusByte, byte(statusSuccess), 9, 0x00)
+	dev := newTestDevice(mock)
+
+	if _, err := dev.ReadStatusByte(context.Background()); err == nil {
+		t.Fatal("ReadStatusByte with mismatched bTag should return error")
+	}
+}
+
+func TestRemoteLocal(t *testing.T) {
+	testCases := []struct {
+		name   string
+		call   func(*Device) error
+		req    bRequest
+		wValue uint16
+	}{
+		{
+			"ren_assert",
+			func(d *Device) error { return d.SetRemoteEnable(context.Background(), true) },
+			renControl, 1,
+		},
+		{
+			"ren_deassert",
+			func(d *Device) error { return d.SetRemoteEnable(context.Background(), false) },
+			renControl, 0,
+		},
+		{
+			"go_to_local",
+			func(d *Device) error { return d.GoToLocal(context.Background()) },
+			goToLocal, 0,
+		},
+		{
+			"local_lockout",
+			func(d *Device) error { return d.LocalLockout(context.Background()) },
+			localLockout, 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockUSBDevice{}
+			mock.reply(getCapabilities, capabilitiesResponse(0, 0, 0x06, 0x0e)...)
+			mock.reply(tc.req, byte(statusSuccess))
+			dev := newTestDevice(mock)
+
+			if err := tc.call(dev); err != nil {
+				t.Fatalf("returned error: %v", err)
+			}
+			c := mock.controls[len(mock.controls)-1]
+			if c.bRequest != uint8(tc.req) || c.wValue != tc.wValue {
+				t.Errorf("request = %d wValue %d, want %d wValue %d",
+					c.bRequest, c.wValue, tc.req, tc.wValue)
+			}
+		})
+	}
+}
+
+func TestRemoteLocalRL1Only(t *testing.T) {
+	// The device reports RL1 without the interface's remote/local bit.
+	mock := &mockUSBDevice{}
+	mock.reply(getCapabilities, capabilitiesResponse(0, 0, 0x04, 0x0a)...)
+	mock.reply(localLockout, byte(statusSuccess))
+	dev := newTestDevice(mock)
+
+	if err := dev.LocalLockout(context.Background()); err != nil {
+		t.Fatalf("LocalLockout returned error: %v", err)
+	}
+	if c := mock.controls[len(mock.controls)-1]; c.bRequest != uint8(localLockout) {
+		t.Errorf("request = %d, want LOCAL_LOCKOUT %d", c.bRequest, localLockout)
+	}
+}
+
+func TestRemoteLocalUnsupported(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.reply(getCapabilities, capabilitiesResponse(0, 0, 0x04, 0x08)...)
+	dev := newTestDevice(mock)
+
+	err := dev.LocalLockout(context.Background())
+	var ue *UnsupportedError
+	if !errors.As(err, &ue) {
+		t.Fatalf("error = %v, want *UnsupportedError", err)
+	}
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("error = %v, want errors.ErrUnsupported", err)
+	}
+	if len(mock.controls) != 1 {
+		t.Errorf("got %d control transfers, want only GET_CAPABILITIES",
+			len(mock.controls))
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	mock := &mockUSBDevice{}
+	dev := newTestDevice(mock)
+
+	if err := dev.Trigger(context.Background()); err != nil {
+		t.Fatalf("Trigger returned error: %v", err)
+	}
+	if len(mock.writes) != 1 {
+		t.Fatalf("expected 1 USB write, got %d", len(mock.writes))
+	}
+	if got, want := mock.writes[0], encodeTriggerHeader(1); string(got) != string(want[:]) {
+		t.Errorf("TRIGGER header = % x, want % x", got, want)
+	}
+	if len(mock.controls) != 0 {
+		t.Errorf("got %d control transfers without fallback, want 0",
+			len(mock.controls))
+	}
+}
+
+func TestTriggerAbortTransferTimeout(t *testing.T) {
+	mock := &mockUSBDevice{}
+	mock.onWrite = func(int) error {
+		return fmt.Errorf("mock: write: %w", driver.ErrTimeout)
+	}
+	mock.reply(initiateAbortBulkOut, byte(statusSuccess), 1)
+	mock.reply(checkAbortBulkOutStatus, byte(statusSuccess), 0, 0, 0, 0, 0, 0, 0)
+	dev := newTestDevice(mock)
+
+	err := dev.Trigger(context.Background())
+	var ae *AbortError
+	if !errors.As(err, &ae) {
+		t.Fatalf("error = %v, want *AbortError", err)
+	}
+	want := []uint8{
+		uint8(initiateAbortBulkOut),
+		uint8(checkAbortBulkOutStatus),
+		requestClearFeature,
+	}
+	if got := mock.requests(); !equalRequests(got, want...) {
+		t.Errorf("control requests = %v, want %v", got, want)
+	}
+}
+
+func TestTriggerFallback(t *testing.T) {
+	testCases := []struct {
+		name       string
+		usb488Intf byte
+		usb488Dev  byte
+		wantMsgID  msgID
+	}{
+		{"dt1", 0x01, 0x01, trigger},
+		{"no_dt1", 0x00, 0x00, devDepMsgOut},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockUSBDevice{}
+			mock.reply(getCapabilities,
+				capabilitiesResponse(0, 0, tc.usb488Intf, tc.usb488Dev)...)
+			dev := newTestDevice(mock)
+			dev.SetTriggerFallback(true)
+
+			if err := dev.Trigger(context.Background()); err != nil {
+				t.Fatalf("Trigger returned error: %v", err)
+			}
+			w := mock.writes[0]
+			if msgID(w[0]) != tc.wantMsgID {
+				t.Errorf("msgID = %d, want %d", w[0], tc.wantMsgID)
+			}
+			if tc.wantMsgID == devDepMsgOut {
+				if got := string(w[bulkOutHeaderSize : bulkOutHeaderSize+5]); got != "*TRG\n" {
+					t.Errorf("fallback payload = %q, want %q", got, "*TRG\n")
+				}
+			}
+		})
+	}
+}
@@ -7,9 +7,12 @@ package usbtmc
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/gotmc/usbtmc/driver"
 )
 
 var visaResourceRe = regexp.MustCompile(
@@ -31,8 +34,19 @@ type VisaResource struct {
 	serialNumber   string
 	interfaceIndex int
 	resourceClass  string
+	// hasInterface is set when the resource string gives the interface
+	// number, which is otherwise the lowest numbered USBTMC interface.
+	hasInterface bool
+	// portPath is the USB port path, such as "1-2.3", given in place of the
+	// serial number as "path=1-2.3".
+	portPath string
 }
 
+// portPathPrefix marks a USB port path given in the serial number field of a
+// VISA resource string, such as "USB0::0x1AB1::0x04CE::path=1-2.3::INSTR",
+// which pins the resource to an instrument on a specific physical port.
+const portPathPrefix = "path="
+
 // NewVisaResource creates a new VisaResource using the given VISA resourceString.
 func NewVisaResource(resourceString string) (*VisaResource, error) {
 	visa := &VisaResource{
@@ -90,9 +104,17 @@ func NewVisaResource(resourceString string) (*VisaResource, error) {
 			return visa, errors.New("visa: interface number error")
 		}
 		visa.interfaceIndex = int(interfaceNumber)
+		visa.hasInterface = true
 	}
 
 	visa.serialNumber = matchMap["serialNumber"]
+	if path, ok := strings.CutPrefix(visa.serialNumber, portPathPrefix); ok {
+		if _, _, err := driver.ParsePortPath(path); err != nil {
+			return visa, errors.New("visa: USB port path error")
+		}
+		visa.serialNumber = ""
+		visa.portPath = path
+	}
 
 	if strings.ToUpper(matchMap["resourceClass"]) != "INSTR" {
 		return visa, errors.New("visa: resource class was not instr")
@@ -102,3 +124,32 @@ func NewVisaResource(resourceString string) (*VisaResource, error) {
 	return visa, nil
 
 }
+
+// usbInterface returns the USBTMC interface number addressed by the resource,
+// or driver.AnyInterface if the resource string does not give one.
+func (v *VisaResource) usbInterface() int {
+	if !v.hasInterface {
+		return driver.AnyInterface
+	}
+	return v.interfaceIndex
+}
+
+// visaResourceString returns the VISA resource string addressing the USBTMC
+// interface described by info. Devices without a serial number are addressed
+// by their USB port path when it is known. The interface number is only
+// included when it is not zero and the device has a serial number or port
+// path, since otherwise it would be parsed as the serial number.
+func visaResourceString(info driver.DeviceInfo) string {
+	s := fmt.Sprintf("USB0::0x%04X::0x%04X", info.VendorID, info.ProductID)
+	id := info.SerialNumber
+	if id == "" && info.PortPath() != "" {
+		id = portPathPrefix + info.PortPath()
+	}
+	if id != "" {
+		s += "::" + id
+		if info.InterfaceNumber != 0 {
+			s += fmt.Sprintf("::%d", info.InterfaceNumber)
+		}
+	}
+	return s + "::INSTR"
+}
@@ -7,6 +7,8 @@ package usbtmc
 
 import (
 	"testing"
+
+	"github.com/gotmc/usbtmc/driver"
 )
 
 func TestParsingVisaResourceString(t *testing.T) {
@@ -122,3 +124,85 @@ func TestParsingVisaResourceString(t *testing.T) {
 		})
 	}
 }
+
+func TestVisaResourceStringRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name string
+		info driver.DeviceInfo
+		want string
+	}{
+		{
+			"with_serial",
+			driver.DeviceInfo{VendorID: 0x2a8d, ProductID: 0x1102, SerialNumber: "MY12345"},
+			"USB0::0x2A8D::0x1102::MY12345::INSTR",
+		},
+		{
+			"with_interface_number",
+			driver.DeviceInfo{
+				VendorID: 0x0957, ProductID: 0x0407, SerialNumber: "MY44", InterfaceNumber: 2,
+			},
+			"USB0::0x0957::0x0407::MY44::2::INSTR",
+		},
+		{
+			"no_serial",
+			driver.DeviceInfo{VendorID: 0x1ab1, ProductID: 0x04ce, InterfaceNumber: 2},
+			"USB0::0x1AB1::0x04CE::INSTR",
+		},
+		{
+			"no_serial_with_port_path",
+			driver.DeviceInfo{
+				VendorID: 0x1ab1, ProductID: 0x04ce, Bus: 1, Path: []int{2, 3}, InterfaceNumber: 2,
+			},
+			"USB0::0x1AB1::0x04CE::path=1-2.3::2::INSTR",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := visaResourceString(tc.info)
+			if got != tc.want {
+				t.Fatalf("visaResourceString = %q, want %q", got, tc.want)
+			}
+			v, err := NewVisaResource(got)
+			if err != nil {
+				t.Fatalf("NewVisaResource(%q) returned error: %v", got, err)
+			}
+			if v.manufacturerID != tc.info.VendorID || v.modelCode != tc.info.ProductID ||
+				v.serialNumber != tc.info.SerialNumber || v.portPath != tc.info.PortPath() {
+				t.Errorf("parsed %+v from %q", v, got)
+			}
+		})
+	}
+}
+
+func TestParsingVisaPortPath(t *testing.T) {
+	testCases := []struct {
+		resourceString string
+		portPath       string
+		isError        bool
+	}{
+		{"USB0::0x1AB1::0x04CE::path=1-2.3::INSTR", "1-2.3", false},
+		{"USB0::0x1AB1::0x04CE::path=3-1::1::INSTR", "3-1", false},
+		{"USB0::0x1AB1::0x04CE::path=1::INSTR", "", true},
+		{"USB0::0x1AB1::0x04CE::path=1-2..3::INSTR", "", true},
+		{"USB0::0x1AB1::0x04CE::path=x-2::INSTR", "", true},
+		{"USB0::0x1AB1::0x04CE::path=1-0::INSTR", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.resourceString, func(t *testing.T) {
+			v, err := NewVisaResource(tc.resourceString)
+			if tc.isError {
+				if err == nil {
+					t.Fatalf("NewVisaResource returned no error, parsed %+v", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewVisaResource returned error: %v", err)
+			}
+			if v.portPath != tc.portPath || v.serialNumber != "" {
+				t.Errorf("portPath = %q, serialNumber = %q, want %q and no serial",
+					v.portPath, v.serialNumber, tc.portPath)
+			}
+		})
+	}
+}